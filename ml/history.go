@@ -0,0 +1,86 @@
+package main
+
+type PriceCutSummary struct {
+	Listings          int     `json:"listings"`
+	WithCuts          int     `json:"with_cuts"`
+	CutShare          float64 `json:"cut_share"`
+	AvgCutPercent     float64 `json:"avg_cut_percent"`
+	MedianCutPercent  float64 `json:"median_cut_percent"`
+	AvgCutsPerListing float64 `json:"avg_cuts_per_listing"`
+}
+
+// PriceCutStats aggregates price reductions per district. A listing counts as
+// cut when its last observed price is below the first one; the cut percent is
+// the total reduction relative to the first price. Prices are compared in the
+// listing's current currency only, so a listing that switched from RSD to EUR
+// is judged by its EUR prices.
+func PriceCutStats(timelines []PriceTimeline) map[string]PriceCutSummary {
+	type accumulator struct {
+		listings    int
+		cutPercents []float64
+		cutCounts   []float64
+	}
+
+	byDistrict := make(map[string]*accumulator)
+	for _, tl := range timelines {
+		prices := inLastCurrency(tl.Prices)
+		if len(prices) == 0 {
+			continue
+		}
+		acc, ok := byDistrict[tl.District]
+		if !ok {
+			acc = &accumulator{}
+			byDistrict[tl.District] = acc
+		}
+		acc.listings++
+
+		first := float64(prices[0].Price)
+		last := float64(prices[len(prices)-1].Price)
+		if first <= 0 || last >= first {
+			continue
+		}
+
+		cuts := 0
+		for i := 1; i < len(prices); i++ {
+			if prices[i].Price < prices[i-1].Price {
+				cuts++
+			}
+		}
+
+		acc.cutPercents = append(acc.cutPercents, (first-last)/first*100)
+		acc.cutCounts = append(acc.cutCounts, float64(cuts))
+	}
+
+	res := make(map[string]PriceCutSummary, len(byDistrict))
+	for district, acc := range byDistrict {
+		summary := PriceCutSummary{
+			Listings: acc.listings,
+			WithCuts: len(acc.cutPercents),
+		}
+		if acc.listings > 0 {
+			summary.CutShare = float64(summary.WithCuts) / float64(acc.listings)
+		}
+		if summary.WithCuts > 0 {
+			summary.AvgCutPercent = Avg(acc.cutPercents)
+			summary.MedianCutPercent = Median(acc.cutPercents)
+			summary.AvgCutsPerListing = Avg(acc.cutCounts)
+		}
+		res[district] = summary
+	}
+	return res
+}
+
+// inLastCurrency keeps the price points in the currency of the last one.
+func inLastCurrency(prices []PricePoint) []PricePoint {
+	if len(prices) == 0 {
+		return nil
+	}
+	currency := prices[len(prices)-1].Currency
+	var res []PricePoint
+	for _, p := range prices {
+		if p.Currency == currency {
+			res = append(res, p)
+		}
+	}
+	return res
+}
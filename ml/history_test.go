@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestPriceCutStats(t *testing.T) {
+	timelines := []PriceTimeline{
+		{Link: "a", District: "Zemun", Prices: []PricePoint{{Price: 100000}, {Price: 95000}, {Price: 90000}}},
+		{Link: "b", District: "Zemun", Prices: []PricePoint{{Price: 200000}, {Price: 210000}, {Price: 180000}}},
+		{Link: "c", District: "Zemun", Prices: []PricePoint{{Price: 150000}}},
+		{Link: "d", District: "Vračar", Prices: []PricePoint{{Price: 300000}, {Price: 320000}}},
+		{Link: "e", District: "Vračar", Prices: nil},
+		// Switched to EUR: the RSD price is not compared with the EUR ones.
+		{Link: "f", District: "Vračar", Prices: []PricePoint{
+			{Price: 35000000, Currency: "RSD"}, {Price: 290000, Currency: "EUR"}, {Price: 290000, Currency: "EUR"},
+		}},
+		{Link: "g", District: "Vračar", Prices: []PricePoint{
+			{Price: 23400000, Currency: "RSD"}, {Price: 22230000, Currency: "RSD"},
+		}},
+	}
+
+	stats := PriceCutStats(timelines)
+
+	zemun, ok := stats["Zemun"]
+	if !ok {
+		t.Fatal("expected stats for Zemun")
+	}
+	if zemun.Listings != 3 {
+		t.Errorf("Zemun listings = %d, want 3", zemun.Listings)
+	}
+	if zemun.WithCuts != 2 {
+		t.Errorf("Zemun with_cuts = %d, want 2", zemun.WithCuts)
+	}
+	if got := Round(zemun.CutShare, 4); got != 0.6667 {
+		t.Errorf("Zemun cut_share = %v, want 0.6667", got)
+	}
+	if zemun.AvgCutPercent != 10 {
+		t.Errorf("Zemun avg_cut_percent = %v, want 10", zemun.AvgCutPercent)
+	}
+	if zemun.AvgCutsPerListing != 1.5 {
+		t.Errorf("Zemun avg_cuts_per_listing = %v, want 1.5", zemun.AvgCutsPerListing)
+	}
+
+	vracar := stats["Vračar"]
+	if vracar.Listings != 3 || vracar.WithCuts != 1 {
+		t.Errorf("Vračar = %+v, want 3 listings, 1 with cuts", vracar)
+	}
+	if got := Round(vracar.AvgCutPercent, 2); got != 5 {
+		t.Errorf("Vračar avg_cut_percent = %v, want 5 for the RSD listing", got)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import "time"
+
+type TimeOnMarketSummary struct {
+	Active                int     `json:"active"`
+	Delisted              int     `json:"delisted"`
+	MedianDaysActive      float64 `json:"median_days_active"`
+	AvgDaysActive         float64 `json:"avg_days_active"`
+	MedianDaysToDelisting float64 `json:"median_days_to_delisting"`
+	AvgDaysToDelisting    float64 `json:"avg_days_to_delisting"`
+}
+
+// TimeOnMarketStats summarizes listing lifetimes per district. Active listings
+// are measured up to now, delisted ones up to the last time they were seen.
+func TimeOnMarketStats(lifetimes []ListingLifetime, now time.Time) map[string]TimeOnMarketSummary {
+	active := make(map[string][]float64)
+	delisted := make(map[string][]float64)
+
+	for _, l := range lifetimes {
+		if l.Active {
+			active[l.District] = append(active[l.District], daysBetween(l.FirstSeenAt, now))
+		} else {
+			delisted[l.District] = append(delisted[l.District], daysBetween(l.FirstSeenAt, l.LastSeenAt))
+		}
+	}
+
+	res := make(map[string]TimeOnMarketSummary)
+	for district, days := range active {
+		summary := res[district]
+		summary.Active = len(days)
+		summary.MedianDaysActive = Median(days)
+		summary.AvgDaysActive = Avg(days)
+		res[district] = summary
+	}
+	for district, days := range delisted {
+		summary := res[district]
+		summary.Delisted = len(days)
+		summary.MedianDaysToDelisting = Median(days)
+		summary.AvgDaysToDelisting = Avg(days)
+		res[district] = summary
+	}
+	return res
+}
+
+func daysBetween(from, to time.Time) float64 {
+	days := to.Sub(from).Hours() / 24
+	if days < 0 {
+		return 0
+	}
+	return days
+}
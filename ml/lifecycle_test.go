@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeOnMarketStats(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	lifetimes := []ListingLifetime{
+		{District: "Zemun", FirstSeenAt: now.Add(-10 * day), LastSeenAt: now, Active: true},
+		{District: "Zemun", FirstSeenAt: now.Add(-30 * day), LastSeenAt: now, Active: true},
+		{District: "Zemun", FirstSeenAt: now.Add(-40 * day), LastSeenAt: now.Add(-20 * day), Active: false},
+		{District: "Vračar", FirstSeenAt: now.Add(-5 * day), LastSeenAt: now.Add(-4 * day), Active: false},
+	}
+
+	stats := TimeOnMarketStats(lifetimes, now)
+
+	zemun := stats["Zemun"]
+	if zemun.Active != 2 || zemun.Delisted != 1 {
+		t.Errorf("Zemun counts = %d active, %d delisted; want 2, 1", zemun.Active, zemun.Delisted)
+	}
+	if zemun.MedianDaysActive != 20 {
+		t.Errorf("Zemun median_days_active = %v, want 20", zemun.MedianDaysActive)
+	}
+	if zemun.MedianDaysToDelisting != 20 {
+		t.Errorf("Zemun median_days_to_delisting = %v, want 20", zemun.MedianDaysToDelisting)
+	}
+
+	vracar := stats["Vračar"]
+	if vracar.Active != 0 || vracar.Delisted != 1 || vracar.AvgDaysToDelisting != 1 {
+		t.Errorf("Vračar = %+v, want 1 delisted after 1 day", vracar)
+	}
+}
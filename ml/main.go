@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -57,6 +58,26 @@ var (
 	}, []string{"algorithm", "district"})
 )
 
+// filterError is a query parameter getFilteredData cannot accept.
+type filterError struct {
+	err error
+}
+
+func (e *filterError) Error() string { return e.err.Error() }
+func (e *filterError) Unwrap() error { return e.err }
+
+// writeDataError answers a failed getFilteredData: 400 for an invalid
+// filter, as /predict does, and 500 for anything else.
+func writeDataError(w http.ResponseWriter, err error) {
+	var invalid *filterError
+	if errors.As(err, &invalid) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
 func main() {
 	_ = godotenv.Load()
 
@@ -71,6 +92,10 @@ func main() {
 	}
 	defer storage.db.Close()
 
+	if err := CheckSchemaVersion(storage); err != nil {
+		log.Fatalf("Incompatible database: %v", err)
+	}
+
 	// mux := http.NewServeMux() // No longer needed if using default ServeMux
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -85,13 +110,17 @@ func main() {
 			"endpoints": []map[string]interface{}{
 				{"path": "/", "description": "API Discovery (this page)"},
 				{"path": "/districts", "description": "List all available municipalities for filtering"},
-				{"path": "/correlation", "description": "Feature correlation matrix", "params": []string{"from", "to", "district", "round"}},
-				{"path": "/stats", "description": "Basic statistics for a field", "params": []string{"field", "from", "to", "district", "round"}},
-				{"path": "/analyze", "description": "Advanced analytics with normality and outlier detection", "params": []string{"fields", "outlier_method", "outlier_field", "from", "to", "district", "round"}},
-				{"path": "/predict", "description": "Linear/Polynomial price prediction with diagnostics", "params": []string{"sqm", "rooms", "floor", "district", "round"}},
-				{"path": "/predict/knn", "description": "K-Nearest Neighbors price prediction", "params": []string{"sqm", "rooms", "floor", "district", "round"}},
-				{"path": "/predict/tree", "description": "Decision Tree price prediction", "params": []string{"sqm", "rooms", "floor", "district", "round"}},
-				{"path": "/predict/boost", "description": "Gradient Boosting (Ensemble) price prediction", "params": []string{"sqm", "rooms", "floor", "district", "round"}},
+				{"path": "/correlation", "description": "Feature correlation matrix", "params": []string{"from", "to", "district", "active", "listing_type", "round"}},
+				{"path": "/stats", "description": "Basic statistics for a field", "params": []string{"field", "from", "to", "district", "active", "listing_type", "currency", "round"}},
+				{"path": "/analyze", "description": "Advanced analytics with normality and outlier detection", "params": []string{"fields", "outlier_method", "outlier_field", "from", "to", "district", "active", "listing_type", "currency", "round"}},
+				{"path": "/predict", "description": "Linear/Polynomial price prediction with diagnostics", "params": []string{"sqm", "rooms", "floor", "district", "active", "listing_type", "currency", "round"}},
+				{"path": "/predict/knn", "description": "K-Nearest Neighbors price prediction", "params": []string{"sqm", "rooms", "floor", "district", "active", "listing_type", "currency", "round"}},
+				{"path": "/predict/tree", "description": "Decision Tree price prediction", "params": []string{"sqm", "rooms", "floor", "district", "active", "listing_type", "currency", "round"}},
+				{"path": "/predict/boost", "description": "Gradient Boosting (Ensemble) price prediction", "params": []string{"sqm", "rooms", "floor", "district", "active", "listing_type", "currency", "round"}},
+				{"path": "/market-time", "description": "Time on market of active and delisted listings per district", "params": []string{"from", "to", "district", "active", "listing_type", "round"}},
+				{"path": "/history", "description": "Price timeline of a single listing", "params": []string{"link"}},
+				{"path": "/history/price-cuts", "description": "Price cut statistics per district", "params": []string{"from", "to", "district", "active", "listing_type", "round"}},
+				{"path": "/yield", "description": "Gross rental yield per district from sale and rent listings", "params": []string{"from", "to", "district", "active", "outlier_method", "currency", "round"}},
 			},
 			"example": "/predict?district=Vracar&sqm=60&rooms=2&floor=3",
 		}
@@ -119,6 +148,7 @@ func main() {
 			district = StandardizeDistrict(district)
 		}
 		excludeOutliers := r.URL.Query().Get("exclude_outliers") != "false"
+		activeOnly := r.URL.Query().Get("active") == "true"
 
 		var from, to time.Time
 		if fromStr != "" {
@@ -128,6 +158,15 @@ func main() {
 			to, _ = time.Parse("2006-01-02", toStr)
 		}
 
+		listingType, err := ParseListingType(r.URL.Query().Get("listing_type"))
+		if err != nil {
+			return nil, from, to, district, &filterError{err}
+		}
+		currency, err := ParseCurrency(r.URL.Query().Get("currency"))
+		if err != nil {
+			return nil, from, to, district, &filterError{err}
+		}
+
 		if from.IsZero() || to.IsZero() {
 			min, max, _ := GetDateRange(storage)
 			if from.IsZero() {
@@ -138,7 +177,7 @@ func main() {
 			}
 		}
 
-		estates, err := GetRealEstateWithoutDuplicate(storage, from, to)
+		estates, err := GetRealEstateWithoutDuplicate(storage, EstateFilter{From: from, To: to, ActiveOnly: activeOnly, ListingType: listingType, Currency: currency})
 		if err != nil {
 			return nil, from, to, district, err
 		}
@@ -205,7 +244,7 @@ func main() {
 	http.HandleFunc("/full", func(w http.ResponseWriter, r *http.Request) {
 		estates, from, to, district, err := getFilteredData(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeDataError(w, err)
 			return
 		}
 
@@ -235,6 +274,19 @@ func main() {
 		method := r.URL.Query().Get("outlier_method")
 		field := r.URL.Query().Get("outlier_field")
 		fieldsStr := r.URL.Query().Get("fields")
+		activeOnly := r.URL.Query().Get("active") == "true"
+		listingType, err := ParseListingType(r.URL.Query().Get("listing_type"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		currency, err := ParseCurrency(r.URL.Query().Get("currency"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
 
 		var from, to time.Time
 		if fromStr != "" {
@@ -254,7 +306,7 @@ func main() {
 			}
 		}
 
-		estates, err := GetRealEstateWithoutDuplicate(storage, from, to)
+		estates, err := GetRealEstateWithoutDuplicate(storage, EstateFilter{From: from, To: to, ActiveOnly: activeOnly, ListingType: listingType, Currency: currency})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -291,6 +343,7 @@ func main() {
 			"from":           from.Format("2006-01-02"),
 			"to":             to.Format("2006-01-02"),
 			"count":          len(estates),
+			"currency":       currency,
 			"outlier_method": method,
 			"outlier_field":  field,
 			"monthly_trend":  Round(CalculateTrend(estates), precision),
@@ -301,7 +354,7 @@ func main() {
 	http.HandleFunc("/correlation", func(w http.ResponseWriter, r *http.Request) {
 		estates, from, to, district, err := getFilteredData(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeDataError(w, err)
 			return
 		}
 
@@ -326,7 +379,7 @@ func main() {
 	http.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
 		estates, from, to, district, err := getFilteredData(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeDataError(w, err)
 			return
 		}
 
@@ -367,6 +420,219 @@ func main() {
 		})
 	})
 
+	http.HandleFunc("/market-time", func(w http.ResponseWriter, r *http.Request) {
+		fromStr := r.URL.Query().Get("from")
+		toStr := r.URL.Query().Get("to")
+		district := r.URL.Query().Get("district")
+		if district != "" {
+			district = StandardizeDistrict(district)
+		}
+		activeOnly := r.URL.Query().Get("active") == "true"
+		listingType, err := ParseListingType(r.URL.Query().Get("listing_type"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		var from, to time.Time
+		if fromStr != "" {
+			from, _ = time.Parse("2006-01-02", fromStr)
+		}
+		if toStr != "" {
+			to, _ = time.Parse("2006-01-02", toStr)
+		}
+
+		lifetimes, err := GetListingLifetimes(storage, EstateFilter{From: from, To: to, ActiveOnly: activeOnly, ListingType: listingType})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		precision := getRoundParam(r, 1)
+		summaries := TimeOnMarketStats(lifetimes, time.Now())
+		for name, summary := range summaries {
+			summary.MedianDaysActive = Round(summary.MedianDaysActive, precision)
+			summary.AvgDaysActive = Round(summary.AvgDaysActive, precision)
+			summary.MedianDaysToDelisting = Round(summary.MedianDaysToDelisting, precision)
+			summary.AvgDaysToDelisting = Round(summary.AvgDaysToDelisting, precision)
+			summaries[name] = summary
+		}
+
+		if district != "" {
+			summary, ok := summaries[district]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]string{"error": "no listings for district"})
+				return
+			}
+			summaries = map[string]TimeOnMarketSummary{district: summary}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"district":  district,
+			"from":      fromStr,
+			"to":        toStr,
+			"districts": summaries,
+		})
+	})
+
+	http.HandleFunc("/yield", func(w http.ResponseWriter, r *http.Request) {
+		fromStr := r.URL.Query().Get("from")
+		toStr := r.URL.Query().Get("to")
+		district := r.URL.Query().Get("district")
+		if district != "" {
+			district = StandardizeDistrict(district)
+		}
+		activeOnly := r.URL.Query().Get("active") == "true"
+		currency, err := ParseCurrency(r.URL.Query().Get("currency"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		var from, to time.Time
+		if fromStr != "" {
+			from, _ = time.Parse("2006-01-02", fromStr)
+		}
+		if toStr != "" {
+			to, _ = time.Parse("2006-01-02", toStr)
+		}
+
+		sales, err := GetRealEstateWithoutDuplicate(storage, EstateFilter{From: from, To: to, ActiveOnly: activeOnly, ListingType: ListingSale, Currency: currency})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rents, err := GetRealEstateWithoutDuplicate(storage, EstateFilter{From: from, To: to, ActiveOnly: activeOnly, ListingType: ListingRent, Currency: currency})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if district != "" {
+			sales = FilterByDistrict(sales, district)
+			rents = FilterByDistrict(rents, district)
+		}
+
+		method := r.URL.Query().Get("outlier_method")
+		sales = AggressiveClean(sales, method)
+		rents = AggressiveClean(rents, method)
+
+		precision := getRoundParam(r, 2)
+		yields := GrossRentalYield(sales, rents)
+		for name, y := range yields {
+			y.SalePricePerSqm = Round(y.SalePricePerSqm, precision)
+			y.MonthlyRentPerSqm = Round(y.MonthlyRentPerSqm, precision)
+			y.GrossYieldPercent = Round(y.GrossYieldPercent, precision)
+			yields[name] = y
+		}
+
+		if district != "" && len(yields) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "not enough sale and rent listings for district"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"district":  district,
+			"from":      fromStr,
+			"to":        toStr,
+			"currency":  currency,
+			"districts": yields,
+		})
+	})
+
+	http.HandleFunc("/history", func(w http.ResponseWriter, r *http.Request) {
+		link := r.URL.Query().Get("link")
+		if link == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "link parameter is required"})
+			return
+		}
+
+		history, err := GetPriceHistory(storage, link)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if len(history) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "no price history for link"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"link":    link,
+			"count":   len(history),
+			"history": history,
+		})
+	})
+
+	http.HandleFunc("/history/price-cuts", func(w http.ResponseWriter, r *http.Request) {
+		fromStr := r.URL.Query().Get("from")
+		toStr := r.URL.Query().Get("to")
+		district := r.URL.Query().Get("district")
+		if district != "" {
+			district = StandardizeDistrict(district)
+		}
+
+		var from, to time.Time
+		if fromStr != "" {
+			from, _ = time.Parse("2006-01-02", fromStr)
+		}
+		if toStr != "" {
+			to, _ = time.Parse("2006-01-02", toStr)
+		}
+
+		listingType, err := ParseListingType(r.URL.Query().Get("listing_type"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		activeOnly := r.URL.Query().Get("active") == "true"
+
+		timelines, err := GetPriceTimelines(storage, EstateFilter{From: from, To: to, ActiveOnly: activeOnly, ListingType: listingType})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		precision := getRoundParam(r, 2)
+		summaries := PriceCutStats(timelines)
+		for name, summary := range summaries {
+			summary.CutShare = Round(summary.CutShare, 4)
+			summary.AvgCutPercent = Round(summary.AvgCutPercent, precision)
+			summary.MedianCutPercent = Round(summary.MedianCutPercent, precision)
+			summary.AvgCutsPerListing = Round(summary.AvgCutsPerListing, precision)
+			summaries[name] = summary
+		}
+
+		if district != "" {
+			summary, ok := summaries[district]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]string{"error": "no price history for district"})
+				return
+			}
+			summaries = map[string]PriceCutSummary{district: summary}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"district":  district,
+			"from":      fromStr,
+			"to":        toStr,
+			"districts": summaries,
+		})
+	})
+
 	http.HandleFunc("/predict", func(w http.ResponseWriter, r *http.Request) {
 		district := r.URL.Query().Get("district")
 		if district != "" {
@@ -377,7 +643,21 @@ func main() {
 		rooms, _ := strconv.ParseFloat(r.URL.Query().Get("rooms"), 64)
 		floor, _ := strconv.ParseFloat(r.URL.Query().Get("floor"), 64)
 
-		estates, err := GetRealEstateWithoutDuplicate(storage, time.Time{}, time.Time{})
+		activeOnly := r.URL.Query().Get("active") == "true"
+		listingType, err := ParseListingType(r.URL.Query().Get("listing_type"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		currency, err := ParseCurrency(r.URL.Query().Get("currency"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		estates, err := GetRealEstateWithoutDuplicate(storage, EstateFilter{ActiveOnly: activeOnly, ListingType: listingType, Currency: currency})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -395,13 +675,19 @@ func main() {
 		pred, pMin, pMax := model.PredictWithInterval(sqm, rooms, floor)
 
 		metricRequests.WithLabelValues("/predict", district).Inc()
-		metricPredictionPrice.WithLabelValues("polynomial", district).Set(pred)
-		metricPredictionSqm.WithLabelValues("polynomial", district).Set(sqm)
-		metricPredictionRooms.WithLabelValues("polynomial", district).Set(rooms)
-		metricPredictionFloor.WithLabelValues("polynomial", district).Set(floor)
-		metricModelR2.WithLabelValues("polynomial", district).Set(model.RSquared)
-		metricModelMAE.WithLabelValues("polynomial", district).Set(model.MAE)
-		metricMarketTrend.WithLabelValues(district).Set(model.Trend)
+		// Prediction gauges are dashboarded for sale listings, prices in EUR;
+		// a monthly rent would overwrite the sale price of the district.
+		if listingType == ListingSale {
+			if currency == CurrencyEUR {
+				metricPredictionPrice.WithLabelValues("polynomial", district).Set(pred)
+				metricModelMAE.WithLabelValues("polynomial", district).Set(model.MAE)
+			}
+			metricPredictionSqm.WithLabelValues("polynomial", district).Set(sqm)
+			metricPredictionRooms.WithLabelValues("polynomial", district).Set(rooms)
+			metricPredictionFloor.WithLabelValues("polynomial", district).Set(floor)
+			metricModelR2.WithLabelValues("polynomial", district).Set(model.RSquared)
+			metricMarketTrend.WithLabelValues(district).Set(model.Trend)
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -409,6 +695,7 @@ func main() {
 			"sqm":         sqm,
 			"rooms":       rooms,
 			"floor":       floor,
+			"currency":    currency,
 			"prediction":  Round(pred, precision),
 			"price_min":   Round(pMin, precision),
 			"price_max":   Round(pMax, precision),
@@ -427,9 +714,12 @@ func main() {
 	http.HandleFunc("/predict/knn", func(w http.ResponseWriter, r *http.Request) {
 		estates, _, _, district, err := getFilteredData(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeDataError(w, err)
 			return
 		}
+		// Already validated by getFilteredData.
+		listingType, _ := ParseListingType(r.URL.Query().Get("listing_type"))
+		currency, _ := ParseCurrency(r.URL.Query().Get("currency"))
 		sqm, _ := strconv.ParseFloat(r.URL.Query().Get("sqm"), 64)
 		rooms, _ := strconv.ParseFloat(r.URL.Query().Get("rooms"), 64)
 		floor, _ := strconv.ParseFloat(r.URL.Query().Get("floor"), 64)
@@ -438,14 +728,19 @@ func main() {
 		prediction := PredictKNN(estates, sqm, rooms, floor, 10)
 
 		metricRequests.WithLabelValues("/predict/knn", district).Inc()
-		metricPredictionPrice.WithLabelValues("knn", district).Set(prediction)
-		metricPredictionSqm.WithLabelValues("knn", district).Set(sqm)
-		metricPredictionRooms.WithLabelValues("knn", district).Set(rooms)
-		metricPredictionFloor.WithLabelValues("knn", district).Set(floor)
+		if listingType == ListingSale {
+			if currency == CurrencyEUR {
+				metricPredictionPrice.WithLabelValues("knn", district).Set(prediction)
+			}
+			metricPredictionSqm.WithLabelValues("knn", district).Set(sqm)
+			metricPredictionRooms.WithLabelValues("knn", district).Set(rooms)
+			metricPredictionFloor.WithLabelValues("knn", district).Set(floor)
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"prediction": Round(prediction, precision),
+			"currency":   currency,
 			"algorithm":  "KNN",
 			"k":          10,
 			"count":      len(estates),
@@ -455,9 +750,12 @@ func main() {
 	http.HandleFunc("/predict/tree", func(w http.ResponseWriter, r *http.Request) {
 		estates, _, _, district, err := getFilteredData(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeDataError(w, err)
 			return
 		}
+		// Already validated by getFilteredData.
+		listingType, _ := ParseListingType(r.URL.Query().Get("listing_type"))
+		currency, _ := ParseCurrency(r.URL.Query().Get("currency"))
 
 		X := make([][]float64, len(estates))
 		Y := make([]float64, len(estates))
@@ -478,14 +776,19 @@ func main() {
 		}
 
 		metricRequests.WithLabelValues("/predict/tree", district).Inc()
-		metricPredictionPrice.WithLabelValues("tree", district).Set(prediction)
-		metricPredictionSqm.WithLabelValues("tree", district).Set(sqm)
-		metricPredictionRooms.WithLabelValues("tree", district).Set(rooms)
-		metricPredictionFloor.WithLabelValues("tree", district).Set(floor)
+		if listingType == ListingSale {
+			if currency == CurrencyEUR {
+				metricPredictionPrice.WithLabelValues("tree", district).Set(prediction)
+			}
+			metricPredictionSqm.WithLabelValues("tree", district).Set(sqm)
+			metricPredictionRooms.WithLabelValues("tree", district).Set(rooms)
+			metricPredictionFloor.WithLabelValues("tree", district).Set(floor)
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"prediction": Round(prediction, precision),
+			"currency":   currency,
 			"algorithm":  "Decision Tree",
 			"max_depth":  5,
 			"count":      len(estates),
@@ -495,9 +798,12 @@ func main() {
 	http.HandleFunc("/predict/boost", func(w http.ResponseWriter, r *http.Request) {
 		estates, _, _, district, err := getFilteredData(r)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeDataError(w, err)
 			return
 		}
+		// Already validated by getFilteredData.
+		listingType, _ := ParseListingType(r.URL.Query().Get("listing_type"))
+		currency, _ := ParseCurrency(r.URL.Query().Get("currency"))
 
 		X := make([][]float64, len(estates))
 		Y := make([]float64, len(estates))
@@ -518,14 +824,19 @@ func main() {
 		}
 
 		metricRequests.WithLabelValues("/predict/boost", district).Inc()
-		metricPredictionPrice.WithLabelValues("boost", district).Set(prediction)
-		metricPredictionSqm.WithLabelValues("boost", district).Set(sqm)
-		metricPredictionRooms.WithLabelValues("boost", district).Set(rooms)
-		metricPredictionFloor.WithLabelValues("boost", district).Set(floor)
+		if listingType == ListingSale {
+			if currency == CurrencyEUR {
+				metricPredictionPrice.WithLabelValues("boost", district).Set(prediction)
+			}
+			metricPredictionSqm.WithLabelValues("boost", district).Set(sqm)
+			metricPredictionRooms.WithLabelValues("boost", district).Set(rooms)
+			metricPredictionFloor.WithLabelValues("boost", district).Set(floor)
+		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"prediction":    Round(prediction, precision),
+			"currency":      currency,
 			"algorithm":     "Gradient Boosting",
 			"trees":         20,
 			"learning_rate": 0.1,
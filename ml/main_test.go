@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteDataError(t *testing.T) {
+	_, parseErr := ParseListingType("lease")
+	cases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"invalid filter", &filterError{parseErr}, http.StatusBadRequest},
+		{"database error", errors.New("failed to query estates"), http.StatusInternalServerError},
+	}
+
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		writeDataError(rec, c.err)
+		if rec.Code != c.want {
+			t.Errorf("%s: status = %d; want %d", c.name, rec.Code, c.want)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"math"
+	"strings"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -68,7 +71,134 @@ func NewConnection(connStr string) (*Storage, error) {
 	return &Storage{db: db}, nil
 }
 
-func GetRealEstateWithoutDuplicate(s *Storage, from, to time.Time) ([]RealEstate, error) {
+// requiredSchemaVersion is the parser migration that added the columns the
+// queries here read (price_eur, price_per_sqm_eur).
+const requiredSchemaVersion = 8
+
+// CheckSchemaVersion fails when the parser has not migrated the database far
+// enough for this engine. The parser owns the schema; see its migrate command.
+func CheckSchemaVersion(s *Storage) error {
+	var version int
+	err := s.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version (run the parser's migrate up): %w", err)
+	}
+	if version < requiredSchemaVersion {
+		return fmt.Errorf("database schema is at version %d, need %d (run the parser's migrate up)", version, requiredSchemaVersion)
+	}
+	return nil
+}
+
+// Listing types stored by the parser. Rent listings carry the monthly rent
+// in price and the monthly rent per square meter in price_per_sqm.
+const (
+	ListingSale = "sale"
+	ListingRent = "rent"
+)
+
+// Currencies prices can be reported in. The parser stores every price
+// converted to EUR as well; RSD figures are converted back with the latest
+// known rate.
+const (
+	CurrencyEUR = "EUR"
+	CurrencyRSD = "RSD"
+)
+
+// EstateFilter narrows the listings loaded for analytics and predictions.
+// An empty ListingType means sale listings, an empty Currency means EUR.
+type EstateFilter struct {
+	From        time.Time
+	To          time.Time
+	ActiveOnly  bool
+	ListingType string
+	Currency    string
+}
+
+func (f EstateFilter) currency() string {
+	if f.Currency == "" {
+		return CurrencyEUR
+	}
+	return f.Currency
+}
+
+func (f EstateFilter) listingType() string {
+	if f.ListingType == "" {
+		return ListingSale
+	}
+	return f.ListingType
+}
+
+// priceBounds returns the plausibility limits used to drop broken listings:
+// minimum price and the open price per square meter range.
+func priceBounds(listingType string) (minPrice, minPerSqm, maxPerSqm int) {
+	if listingType == ListingRent {
+		return 100, 2, 100
+	}
+	return 30000, 300, 15000
+}
+
+func ParseListingType(raw string) (string, error) {
+	switch raw {
+	case "", ListingSale:
+		return ListingSale, nil
+	case ListingRent:
+		return ListingRent, nil
+	}
+	return "", fmt.Errorf("unknown listing_type %q, expected %q or %q", raw, ListingSale, ListingRent)
+}
+
+func ParseCurrency(raw string) (string, error) {
+	switch strings.ToUpper(raw) {
+	case "", CurrencyEUR:
+		return CurrencyEUR, nil
+	case CurrencyRSD:
+		return CurrencyRSD, nil
+	}
+	return "", fmt.Errorf("unknown currency %q, expected %q or %q", raw, CurrencyEUR, CurrencyRSD)
+}
+
+// GetExchangeRate returns the most recent number of currency units per 1 EUR.
+func GetExchangeRate(s *Storage, currency string) (float64, error) {
+	if currency == CurrencyEUR {
+		return 1, nil
+	}
+
+	var rate float64
+	err := s.db.QueryRow("SELECT rate FROM exchange_rates WHERE currency = $1 ORDER BY date DESC LIMIT 1", currency).Scan(&rate)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("no exchange rate for %s", currency)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query exchange rate: %w", err)
+	}
+	return rate, nil
+}
+
+// ConvertFromEUR rewrites EUR prices in another currency at the given rate.
+func ConvertFromEUR(estates []RealEstate, currency string, rate float64) {
+	for i := range estates {
+		estates[i].Price = scalePrice(estates[i].Price, rate)
+		estates[i].PricePerSquareMeter = scalePrice(estates[i].PricePerSquareMeter, rate)
+		estates[i].Currency = currency
+	}
+}
+
+func scalePrice(price int32, rate float64) int32 {
+	return int32(math.Min(math.Round(float64(price)*rate), math.MaxInt32))
+}
+
+func GetRealEstateWithoutDuplicate(s *Storage, filter EstateFilter) ([]RealEstate, error) {
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	minPrice, minPerSqm, maxPerSqm := priceBounds(filter.listingType())
+
+	// One representative per duplicate cluster (the most recently parsed
+	// listing); listings not clustered yet stand for themselves. Prices are
+	// the EUR-normalized ones so RSD listings are included; bounds apply in EUR.
 	query := `
 	SELECT DISTINCT
 	price,
@@ -79,23 +209,31 @@ func GetRealEstateWithoutDuplicate(s *Storage, from, to time.Time) ([]RealEstate
 	floor_total,
 	district,
 	parsing_date
-	FROM estates
-	WHERE price > 30000 AND currency = 'EUR'
-	AND district != '' AND LOWER(district) != 'beograd'
-	AND square_meter > 5
-	AND price_per_sqm > 300 AND price_per_sqm < 15000
+	FROM (
+		SELECT DISTINCT ON (COALESCE(cluster_id, id))
+		price_eur AS price, price_per_sqm_eur AS price_per_sqm,
+		square_meter, quantity_room, floor, floor_total, district, parsing_date
+		FROM estates
+		WHERE listing_type = ` + arg(filter.listingType()) + `
+		AND price_eur > ` + arg(minPrice) + `
+		AND district != '' AND LOWER(district) != 'beograd'
+		AND square_meter > 5
+		AND price_per_sqm_eur > ` + arg(minPerSqm) + ` AND price_per_sqm_eur < ` + arg(maxPerSqm) + `
 	`
 
-	var args []interface{}
-	if !from.IsZero() {
-		query += " AND parsing_date >= $1"
-		args = append(args, from)
+	if !filter.From.IsZero() {
+		query += " AND parsing_date >= " + arg(filter.From)
 	}
-	if !to.IsZero() {
-		placeholder := fmt.Sprintf("$%d", len(args)+1)
-		query += " AND parsing_date <= " + placeholder
-		args = append(args, to)
+	if !filter.To.IsZero() {
+		query += " AND parsing_date <= " + arg(filter.To)
 	}
+	if filter.ActiveOnly {
+		query += " AND active"
+	}
+	query += `
+		ORDER BY COALESCE(cluster_id, id), parsing_date DESC
+	) representatives
+	`
 
 	rows, err := s.db.Query(query, args...)
 	if err != nil {
@@ -124,6 +262,7 @@ func GetRealEstateWithoutDuplicate(s *Storage, from, to time.Time) ([]RealEstate
 		e.Floor = NormalizeFloorValue(e.Floor, e.FloorTotal)
 		e.FloorTotal = NormalizeFloorValue(e.FloorTotal, -5)
 		e.District = StandardizeDistrict(e.District)
+		e.Currency = CurrencyEUR
 
 		estates = append(estates, e)
 	}
@@ -132,6 +271,14 @@ func GetRealEstateWithoutDuplicate(s *Storage, from, to time.Time) ([]RealEstate
 		return nil, fmt.Errorf("rows error: %w", err)
 	}
 
+	if currency := filter.currency(); currency != CurrencyEUR {
+		rate, err := GetExchangeRate(s, currency)
+		if err != nil {
+			return nil, err
+		}
+		ConvertFromEUR(estates, currency, rate)
+	}
+
 	return estates, nil
 }
 
@@ -143,3 +290,163 @@ func GetDateRange(s *Storage) (time.Time, time.Time, error) {
 	}
 	return min, max, nil
 }
+
+type ListingLifetime struct {
+	District    string
+	FirstSeenAt time.Time
+	LastSeenAt  time.Time
+	Active      bool
+}
+
+// GetListingLifetimes returns the market lifetime of every listing that was on
+// the market at some point within the period.
+func GetListingLifetimes(s *Storage, filter EstateFilter) ([]ListingLifetime, error) {
+	query := `
+	SELECT district, first_seen_at, last_seen_at, active
+	FROM estates
+	WHERE first_seen_at IS NOT NULL AND last_seen_at IS NOT NULL
+	AND district != '' AND LOWER(district) != 'beograd'
+	AND listing_type = $1
+	`
+
+	args := []interface{}{filter.listingType()}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		query += fmt.Sprintf(" AND last_seen_at >= $%d", len(args))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		query += fmt.Sprintf(" AND first_seen_at <= $%d", len(args))
+	}
+	if filter.ActiveOnly {
+		query += " AND active"
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query listing lifetimes: %w", err)
+	}
+	defer rows.Close()
+
+	var lifetimes []ListingLifetime
+	for rows.Next() {
+		var l ListingLifetime
+		if err := rows.Scan(&l.District, &l.FirstSeenAt, &l.LastSeenAt, &l.Active); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		l.District = StandardizeDistrict(l.District)
+		lifetimes = append(lifetimes, l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return lifetimes, nil
+}
+
+type PricePoint struct {
+	Price               int32     `json:"price"`
+	Currency            string    `json:"currency"`
+	PricePerSquareMeter int32     `json:"price_per_sqm"`
+	ObservedAt          time.Time `json:"observed_at"`
+}
+
+type PriceTimeline struct {
+	Link     string       `json:"link"`
+	District string       `json:"district"`
+	Prices   []PricePoint `json:"prices"`
+}
+
+func GetPriceHistory(s *Storage, link string) ([]PricePoint, error) {
+	query := `
+	SELECT price, currency, price_per_sqm, observed_at
+	FROM estate_price_history
+	WHERE link = $1
+	ORDER BY observed_at
+	`
+
+	rows, err := s.db.Query(query, link)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []PricePoint
+	for rows.Next() {
+		var p PricePoint
+		var currency sql.NullString
+		var pricePerSqm sql.NullInt32
+		if err := rows.Scan(&p.Price, &currency, &pricePerSqm, &p.ObservedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		p.Currency = currency.String
+		p.PricePerSquareMeter = pricePerSqm.Int32
+		history = append(history, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return history, nil
+}
+
+// GetPriceTimelines returns the price history of every listing observed
+// within the period, grouped per listing in observation order. Prices keep
+// the currency they were observed in.
+func GetPriceTimelines(s *Storage, filter EstateFilter) ([]PriceTimeline, error) {
+	query := `
+	SELECT h.link, e.district, h.price, h.currency, h.price_per_sqm, h.observed_at
+	FROM estate_price_history h
+	JOIN estates e ON e.link = h.link
+	WHERE h.price > 0
+	AND e.district != '' AND LOWER(e.district) != 'beograd'
+	AND e.listing_type = $1
+	`
+
+	args := []interface{}{filter.listingType()}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		query += fmt.Sprintf(" AND h.observed_at >= $%d", len(args))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		query += fmt.Sprintf(" AND h.observed_at <= $%d", len(args))
+	}
+	if filter.ActiveOnly {
+		query += " AND e.active"
+	}
+	query += " ORDER BY h.link, h.observed_at"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price history: %w", err)
+	}
+	defer rows.Close()
+
+	var timelines []PriceTimeline
+	for rows.Next() {
+		var link, district string
+		var p PricePoint
+		var currency sql.NullString
+		var pricePerSqm sql.NullInt32
+		if err := rows.Scan(&link, &district, &p.Price, &currency, &pricePerSqm, &p.ObservedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		p.Currency = currency.String
+		p.PricePerSquareMeter = pricePerSqm.Int32
+
+		if n := len(timelines); n == 0 || timelines[n-1].Link != link {
+			timelines = append(timelines, PriceTimeline{Link: link, District: StandardizeDistrict(district)})
+		}
+		last := &timelines[len(timelines)-1]
+		last.Prices = append(last.Prices, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return timelines, nil
+}
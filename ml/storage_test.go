@@ -2,9 +2,9 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"testing"
-	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -28,7 +28,7 @@ func TestGetRealEstateWithoutDuplicate(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to connect to database: %v", err)
 	}
-	result, err := GetRealEstateWithoutDuplicate(storage, time.Time{}, time.Time{})
+	result, err := GetRealEstateWithoutDuplicate(storage, EstateFilter{})
 	if err != nil {
 		t.Fatalf("failed to get real estate without duplicate: %v", err)
 	}
@@ -66,3 +66,32 @@ func correlationTable(estates []RealEstate, title string) {
 		fmt.Println()
 	}
 }
+
+func TestParseCurrency(t *testing.T) {
+	cases := map[string]string{"": CurrencyEUR, "EUR": CurrencyEUR, "rsd": CurrencyRSD, "RSD": CurrencyRSD}
+	for raw, want := range cases {
+		got, err := ParseCurrency(raw)
+		if err != nil || got != want {
+			t.Errorf("ParseCurrency(%q) = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+
+	if _, err := ParseCurrency("USD"); err == nil {
+		t.Error("expected an error for USD")
+	}
+}
+
+func TestConvertFromEUR(t *testing.T) {
+	estates := []RealEstate{
+		{Price: 100000, PricePerSquareMeter: 2000, Currency: CurrencyEUR},
+		{Price: 30000000, PricePerSquareMeter: 15000, Currency: CurrencyEUR},
+	}
+	ConvertFromEUR(estates, CurrencyRSD, 117.2)
+
+	if estates[0].Price != 11720000 || estates[0].PricePerSquareMeter != 234400 || estates[0].Currency != CurrencyRSD {
+		t.Errorf("converted = %+v", estates[0])
+	}
+	if estates[1].Price != math.MaxInt32 {
+		t.Errorf("price beyond int32 = %d; want it clamped", estates[1].Price)
+	}
+}
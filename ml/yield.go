@@ -0,0 +1,51 @@
+package main
+
+type RentalYield struct {
+	SaleCount         int     `json:"sale_count"`
+	RentCount         int     `json:"rent_count"`
+	SalePricePerSqm   float64 `json:"sale_price_per_sqm"`
+	MonthlyRentPerSqm float64 `json:"monthly_rent_per_sqm"`
+	GrossYieldPercent float64 `json:"gross_yield_percent"`
+}
+
+// GrossRentalYield compares the median sale price per square meter with the
+// median monthly rent per square meter of each district:
+// yield = 12 * rent per sqm / sale price per sqm * 100.
+// Districts without both sale and rent listings are left out.
+func GrossRentalYield(sales, rents []RealEstate) map[string]RentalYield {
+	salePerSqm := perSqmByDistrict(sales)
+	rentPerSqm := perSqmByDistrict(rents)
+
+	res := make(map[string]RentalYield)
+	for district, saleValues := range salePerSqm {
+		rentValues, ok := rentPerSqm[district]
+		if !ok {
+			continue
+		}
+
+		sale := Median(saleValues)
+		rent := Median(rentValues)
+		if sale <= 0 {
+			continue
+		}
+
+		res[district] = RentalYield{
+			SaleCount:         len(saleValues),
+			RentCount:         len(rentValues),
+			SalePricePerSqm:   sale,
+			MonthlyRentPerSqm: rent,
+			GrossYieldPercent: rent * 12 / sale * 100,
+		}
+	}
+	return res
+}
+
+func perSqmByDistrict(estates []RealEstate) map[string][]float64 {
+	res := make(map[string][]float64)
+	for _, e := range estates {
+		if e.SquareMeter > 0 {
+			res[e.District] = append(res[e.District], float64(e.Price)/float64(e.SquareMeter))
+		}
+	}
+	return res
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestGrossRentalYield(t *testing.T) {
+	sales := []RealEstate{
+		{District: "Zemun", Price: 100000, SquareMeter: 50},
+		{District: "Zemun", Price: 120000, SquareMeter: 50},
+		{District: "Zemun", Price: 110000, SquareMeter: 50},
+		{District: "Vračar", Price: 300000, SquareMeter: 100},
+	}
+	rents := []RealEstate{
+		{District: "Zemun", Price: 500, SquareMeter: 50},
+		{District: "Zemun", Price: 550, SquareMeter: 50},
+		{District: "Zvezdara", Price: 400, SquareMeter: 40},
+	}
+
+	yields := GrossRentalYield(sales, rents)
+
+	zemun, ok := yields["Zemun"]
+	if !ok {
+		t.Fatal("expected yield for Zemun")
+	}
+	if zemun.SaleCount != 3 || zemun.RentCount != 2 {
+		t.Errorf("Zemun counts = %d sale, %d rent; want 3, 2", zemun.SaleCount, zemun.RentCount)
+	}
+	if zemun.SalePricePerSqm != 2200 {
+		t.Errorf("Zemun sale_price_per_sqm = %v, want 2200", zemun.SalePricePerSqm)
+	}
+	if zemun.MonthlyRentPerSqm != 10.5 {
+		t.Errorf("Zemun monthly_rent_per_sqm = %v, want 10.5", zemun.MonthlyRentPerSqm)
+	}
+	if got := Round(zemun.GrossYieldPercent, 2); got != 5.73 {
+		t.Errorf("Zemun gross_yield_percent = %v, want 5.73", got)
+	}
+
+	if _, ok := yields["Vračar"]; ok {
+		t.Error("Vračar has no rent listings and must be left out")
+	}
+	if _, ok := yields["Zvezdara"]; ok {
+		t.Error("Zvezdara has no sale listings and must be left out")
+	}
+}
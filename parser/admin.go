@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SiteProgress is the live state of a running site, updated after every
+// saved page.
+type SiteProgress struct {
+	Site       string    `json:"site"`
+	RunID      string    `json:"run_id,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	Resumed    bool      `json:"resumed"`
+	Page       int       `json:"page"` // last completed page
+	ItemsSoFar int       `json:"items_so_far"`
+	TotalItems int       `json:"total_items,omitempty"`
+	TotalPages int       `json:"total_pages,omitempty"` // pages expected, see crawlPlan
+	Percent    float64   `json:"percent"`
+	Cancelling bool      `json:"cancelling"`
+}
+
+// liveRun tracks a run started by the Scheduler. Its methods do nothing on a
+// nil liveRun, so runs outside the scheduler simply pass nil.
+type liveRun struct {
+	mu       sync.Mutex
+	progress SiteProgress
+	cancel   func()
+}
+
+func newLiveRun(site string, cancel func()) *liveRun {
+	live := &liveRun{
+		progress: SiteProgress{Site: site, StartedAt: time.Now()},
+	}
+	live.cancel = func() {
+		live.mu.Lock()
+		live.progress.Cancelling = true
+		live.mu.Unlock()
+		cancel()
+	}
+	return live
+}
+
+// start records the run id and start of the run, which differ from the
+// trigger when an interrupted run is resumed.
+func (l *liveRun) start(runID string, startedAt time.Time, resumed bool, page, itemsSoFar, totalItems int) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.progress.RunID = runID
+	l.progress.StartedAt = startedAt
+	l.progress.Resumed = resumed
+	l.progress.Page = page
+	l.progress.ItemsSoFar = itemsSoFar
+	l.progress.TotalItems = totalItems
+}
+
+func (l *liveRun) update(page, itemsSoFar, totalItems, totalPages int, percent float64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.progress.Page = page
+	l.progress.ItemsSoFar = itemsSoFar
+	l.progress.TotalItems = totalItems
+	l.progress.TotalPages = totalPages
+	l.progress.Percent = percent
+}
+
+func (l *liveRun) snapshot() SiteProgress {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.progress
+}
+
+// adminHandler serves the admin API under /admin/, every request
+// authenticated with "Authorization: Bearer <token>":
+//
+//	POST /admin/trigger?site=NAME  start a site, or every site without site
+//	GET  /admin/progress           progress of the running sites
+//	POST /admin/cancel?site=NAME   cancel a site, or every running site
+func adminHandler(sc *Scheduler, token string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /admin/trigger", func(w http.ResponseWriter, r *http.Request) {
+		started, running, err := sc.Trigger(r.URL.Query().Get("site"))
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		status := http.StatusAccepted
+		if len(started) == 0 {
+			status = http.StatusConflict
+		}
+		writeJSON(w, status, map[string]interface{}{
+			"started":         nonNil(started),
+			"already_running": nonNil(running),
+		})
+	})
+
+	mux.HandleFunc("GET /admin/progress", func(w http.ResponseWriter, r *http.Request) {
+		progress := sc.Progress()
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"count":   len(progress),
+			"running": progress,
+		})
+	})
+
+	mux.HandleFunc("POST /admin/cancel", func(w http.ResponseWriter, r *http.Request) {
+		cancelled := sc.Cancel(r.URL.Query().Get("site"))
+		status := http.StatusAccepted
+		if len(cancelled) == 0 {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, map[string]interface{}{
+			"cancelled": nonNil(cancelled),
+		})
+	})
+
+	return requireToken(token, mux)
+}
+
+// requireToken rejects requests without the bearer token.
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
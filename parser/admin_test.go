@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdminAPI(t *testing.T) {
+	site := testSite(t, "4zida.rs")
+	sc := NewScheduler(context.Background(), nil, []Site{site, testSite(t, "halooglasi.com")}, 0)
+
+	started := make(chan struct{}, 2)
+	stopped := make(chan error, 2)
+	sc.run = func(ctx context.Context, site Site, live *liveRun) {
+		live.start("20261017T040000Z", time.Now(), false, 0, 0, 0)
+		live.update(3, 75, 600, 24, 12.5)
+		started <- struct{}{}
+		<-ctx.Done()
+		stopped <- ctx.Err()
+	}
+
+	server := httptest.NewServer(adminHandler(sc, "secret"))
+	defer server.Close()
+
+	call := func(method, path, token string, out interface{}) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if out != nil {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	if status := call("GET", "/admin/progress", "", nil); status != http.StatusUnauthorized {
+		t.Errorf("no token: status %d; want 401", status)
+	}
+	if status := call("GET", "/admin/progress", "wrong", nil); status != http.StatusUnauthorized {
+		t.Errorf("wrong token: status %d; want 401", status)
+	}
+	if status := call("GET", "/admin/trigger", "secret", nil); status != http.StatusMethodNotAllowed {
+		t.Errorf("GET trigger: status %d; want 405", status)
+	}
+	if status := call("POST", "/admin/trigger?site=nosuch.rs", "secret", nil); status != http.StatusNotFound {
+		t.Errorf("unknown site: status %d; want 404", status)
+	}
+
+	var triggered struct {
+		Started        []string `json:"started"`
+		AlreadyRunning []string `json:"already_running"`
+	}
+	if status := call("POST", "/admin/trigger?site=4zida.rs", "secret", &triggered); status != http.StatusAccepted {
+		t.Fatalf("trigger: status %d; want 202", status)
+	}
+	if len(triggered.Started) != 1 || triggered.Started[0] != "4zida.rs" {
+		t.Errorf("started = %v", triggered.Started)
+	}
+	<-started
+
+	if status := call("POST", "/admin/trigger?site=4zida.rs", "secret", &triggered); status != http.StatusConflict {
+		t.Errorf("second trigger: status %d; want 409", status)
+	}
+	if len(triggered.AlreadyRunning) != 1 {
+		t.Errorf("already_running = %v", triggered.AlreadyRunning)
+	}
+
+	var progress struct {
+		Count   int            `json:"count"`
+		Running []SiteProgress `json:"running"`
+	}
+	call("GET", "/admin/progress", "secret", &progress)
+	if progress.Count != 1 {
+		t.Fatalf("progress count = %d; want 1", progress.Count)
+	}
+	if p := progress.Running[0]; p.Site != "4zida.rs" || p.RunID != "20261017T040000Z" || p.Page != 3 || p.ItemsSoFar != 75 || p.TotalItems != 600 || p.TotalPages != 24 || p.Percent != 12.5 {
+		t.Errorf("unexpected progress %+v", p)
+	}
+
+	var cancelled struct {
+		Cancelled []string `json:"cancelled"`
+	}
+	if status := call("POST", "/admin/cancel", "secret", &cancelled); status != http.StatusAccepted {
+		t.Errorf("cancel: status %d; want 202", status)
+	}
+	if len(cancelled.Cancelled) != 1 || cancelled.Cancelled[0] != "4zida.rs" {
+		t.Errorf("cancelled = %v", cancelled.Cancelled)
+	}
+	if err := <-stopped; err != context.Canceled {
+		t.Errorf("run stopped with %v; want context.Canceled", err)
+	}
+
+	// The site leaves the registry once its run returns.
+	deadline := time.Now().Add(time.Second)
+	for len(sc.Progress()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := len(sc.Progress()); n != 0 {
+		t.Errorf("%d sites still running", n)
+	}
+	if status := call("POST", "/admin/cancel?site=4zida.rs", "secret", nil); status != http.StatusNotFound {
+		t.Errorf("cancel of an idle site: status %d; want 404", status)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// HTTP modes of the scraper. In record mode every response fetched by a
+// collector is written to the archive; in replay mode responses are served
+// from the archive only and the live sites are never contacted.
+const (
+	HTTPModeLive   = "live"
+	HTTPModeRecord = "record"
+	HTTPModeReplay = "replay"
+)
+
+var (
+	httpMode      = HTTPModeLive
+	httpTransport http.RoundTripper // nil means colly's default transport
+)
+
+// configureHTTPMode switches all collectors created by setupParser to the
+// given mode. dir is the archive directory used by record and replay.
+func configureHTTPMode(mode string, dir string) error {
+	switch mode {
+	case "", HTTPModeLive:
+		httpMode, httpTransport = HTTPModeLive, nil
+		return nil
+	case HTTPModeRecord, HTTPModeReplay:
+	default:
+		return fmt.Errorf("unknown HTTP mode %q", mode)
+	}
+
+	archive, err := OpenHTTPArchive(dir)
+	if err != nil {
+		return err
+	}
+
+	httpMode = mode
+	if mode == HTTPModeRecord {
+		httpTransport = archive.Recorder(http.DefaultTransport)
+	} else {
+		httpTransport = archive.Replayer()
+	}
+	slog.Info("HTTP archive enabled", "mode", mode, "dir", dir)
+	return nil
+}
+
+// ArchivedResponse is one recorded HTTP exchange.
+type ArchivedResponse struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	FetchedAt  time.Time   `json:"fetched_at"`
+}
+
+// HTTPArchive stores responses as one JSON file per method and URL, so a
+// later recording of the same page replaces the earlier one.
+type HTTPArchive struct {
+	dir string
+}
+
+func OpenHTTPArchive(dir string) (*HTTPArchive, error) {
+	if dir == "" {
+		return nil, errors.New("HTTP archive directory is empty")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create HTTP archive %s: %w", dir, err)
+	}
+	return &HTTPArchive{dir: dir}, nil
+}
+
+func (a *HTTPArchive) path(method string, url string) string {
+	sum := sha256.Sum256([]byte(method + " " + url))
+	return filepath.Join(a.dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+func (a *HTTPArchive) Save(r ArchivedResponse) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode archived response for %s: %w", r.URL, err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated
+	// entry behind.
+	path := a.path(r.Method, r.URL)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write archived response for %s: %w", r.URL, err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// ErrNotArchived is returned in replay mode for requests that were never
+// recorded.
+var ErrNotArchived = errors.New("response not found in HTTP archive")
+
+func (a *HTTPArchive) Load(method string, url string) (ArchivedResponse, error) {
+	var r ArchivedResponse
+
+	data, err := os.ReadFile(a.path(method, url))
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, fmt.Errorf("%w: %s %s", ErrNotArchived, method, url)
+	}
+	if err != nil {
+		return r, fmt.Errorf("failed to read archived response for %s: %w", url, err)
+	}
+
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("failed to decode archived response for %s: %w", url, err)
+	}
+	return r, nil
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Recorder returns a transport that fetches through next and archives every
+// response it receives, whatever its status code.
+func (a *HTTPArchive) Recorder(next http.RoundTripper) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body of %s: %w", req.URL, err)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		entry := ArchivedResponse{
+			Method:     req.Method,
+			URL:        req.URL.String(),
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       body,
+			FetchedAt:  time.Now().UTC(),
+		}
+		if err := a.Save(entry); err != nil {
+			// A failed recording must not fail the run itself.
+			slog.Error("Failed to archive response", "url", entry.URL, "error", err)
+		}
+		return resp, nil
+	})
+}
+
+// Replayer returns a transport that answers every request from the archive.
+func (a *HTTPArchive) Replayer() http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		entry, err := a.Load(req.Method, req.URL.String())
+		if err != nil {
+			return nil, err
+		}
+
+		header := entry.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", entry.StatusCode, http.StatusText(entry.StatusCode)),
+			StatusCode:    entry.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+			ContentLength: int64(len(entry.Body)),
+			Request:       req,
+		}, nil
+	})
+}
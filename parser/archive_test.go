@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHTTPArchiveRecordReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<html><body>%s</body></html>", r.URL.Query().Get("page"))
+	}))
+
+	archive, err := OpenHTTPArchive(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url := server.URL + "/stanovi?page=2"
+	recorder := &http.Client{Transport: archive.Recorder(http.DefaultTransport)}
+	resp, err := recorder.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	// Replay must work without the live site.
+	server.Close()
+
+	replayer := &http.Client{Transport: archive.Replayer()}
+	resp, err = replayer.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d; want 200", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if string(body) != "<html><body>2</body></html>" {
+		t.Errorf("body = %q", body)
+	}
+
+	entry, err := archive.Load(http.MethodGet, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.URL != url || time.Since(entry.FetchedAt) > time.Minute {
+		t.Errorf("unexpected entry %s fetched at %v", entry.URL, entry.FetchedAt)
+	}
+
+	_, err = replayer.Get(server.URL + "/stanovi?page=3")
+	if !errors.Is(err, ErrNotArchived) {
+		t.Errorf("expected ErrNotArchived, got %v", err)
+	}
+}
+
+func TestReplayListingPage(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := OpenHTTPArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := os.ReadFile(filepath.Join("testdata", "4zida_list.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	site := testSite(t, "4zida.rs")
+	err = archive.Save(ArchivedResponse{
+		Method:     http.MethodGet,
+		URL:        site.URLs.StartURL,
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:       body,
+		FetchedAt:  time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := configureHTTPMode(HTTPModeReplay, dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { configureHTTPMode(HTTPModeLive, "") })
+
+	estates, _, err := site.List(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(estates) != 2 {
+		t.Fatalf("got %d estates; want 2", len(estates))
+	}
+	if estates[0].Price != 150000 || estates[1].Street != "Bulevar kralja Aleksandra 300" {
+		t.Errorf("unexpected estates %+v", estates)
+	}
+
+	// Pages that were never recorded fail instead of reaching the live site.
+	if _, _, err := site.List(2); !errors.Is(err, ErrNotArchived) {
+		t.Errorf("expected ErrNotArchived for page 2, got %v", err)
+	}
+}
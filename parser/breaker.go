@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states, as exported by parser_circuit_breaker_state.
+const (
+	BreakerClosed   = 0 // pages are fetched normally
+	BreakerHalfOpen = 1 // the pause is over, the next page decides
+	BreakerOpen     = 2 // the site is paused
+)
+
+// CircuitBreaker pauses a site after Threshold consecutive listing pages
+// failed even with retries. After the cooldown one trial page is fetched: if
+// it loads the breaker closes, otherwise the site gives up for this run and
+// the breaker opens again.
+type CircuitBreaker struct {
+	site      string
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = make(map[string]*CircuitBreaker)
+)
+
+// breakerFor returns the breaker of a site, shared by all its runs in this
+// process so a run started right after a failed one still waits.
+func breakerFor(site string, p FetchPolicy) *CircuitBreaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+
+	b, ok := breakers[site]
+	if !ok {
+		b = newCircuitBreaker(site, p.BreakerThreshold, p.BreakerCooldown)
+		breakers[site] = b
+	}
+	return b
+}
+
+func newCircuitBreaker(site string, threshold int, cooldown time.Duration) *CircuitBreaker {
+	breakerState.WithLabelValues(site).Set(BreakerClosed)
+	return &CircuitBreaker{site: site, threshold: threshold, cooldown: cooldown}
+}
+
+// Wait blocks while the breaker is open and turns it half-open once the
+// cooldown is over. It only fails when ctx is cancelled.
+func (b *CircuitBreaker) Wait(ctx context.Context) error {
+	b.mu.Lock()
+	if b.state != BreakerOpen {
+		b.mu.Unlock()
+		return nil
+	}
+	remaining := time.Until(b.openedAt.Add(b.cooldown))
+	b.mu.Unlock()
+
+	if remaining > 0 {
+		slog.Warn("Circuit breaker open, pausing site", "site", b.site, "for", remaining)
+		if err := sleepContext(ctx, remaining); err != nil {
+			return err
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen {
+		b.setState(BreakerHalfOpen)
+	}
+	return nil
+}
+
+// Success records a page that loaded and closes the breaker.
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	if b.state != BreakerClosed {
+		slog.Info("Circuit breaker closed", "site", b.site)
+		b.setState(BreakerClosed)
+	}
+}
+
+// Failure records a page that failed after its retries. It reports whether
+// the run should give up because the trial page after a pause failed too.
+func (b *CircuitBreaker) Failure() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 {
+		return false
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen {
+		b.open()
+		return true
+	}
+	if b.state == BreakerClosed && b.failures >= b.threshold {
+		b.open()
+	}
+	return false
+}
+
+func (b *CircuitBreaker) State() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *CircuitBreaker) open() {
+	slog.Error("Circuit breaker opened", "site", b.site, "failures", b.failures, "cooldown", b.cooldown)
+	b.openedAt = time.Now()
+	b.setState(BreakerOpen)
+	breakerTrips.WithLabelValues(b.site).Inc()
+}
+
+func (b *CircuitBreaker) setState(state int) {
+	b.state = state
+	breakerState.WithLabelValues(b.site).Set(float64(state))
+}
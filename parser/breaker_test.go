@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker("test.rs", 2, 20*time.Millisecond)
+	ctx := context.Background()
+
+	if b.Failure() || b.State() != BreakerClosed {
+		t.Fatalf("one failure: state %d", b.State())
+	}
+	b.Success()
+	if b.Failure() || b.State() != BreakerClosed {
+		t.Fatalf("success did not reset the failure count: state %d", b.State())
+	}
+	if b.Failure() || b.State() != BreakerOpen {
+		t.Fatalf("two failures: state %d; want open", b.State())
+	}
+
+	start := time.Now()
+	if err := b.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("open breaker paused only %v", elapsed)
+	}
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("after the cooldown: state %d; want half-open", b.State())
+	}
+
+	// The trial page fails: give up and open again.
+	if !b.Failure() || b.State() != BreakerOpen {
+		t.Fatalf("failed trial: state %d; want open and give up", b.State())
+	}
+
+	b.Wait(ctx)
+	b.Success()
+	if b.State() != BreakerClosed {
+		t.Errorf("successful trial: state %d; want closed", b.State())
+	}
+
+	b.Failure()
+	b.Failure()
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := b.Wait(cancelled); err == nil {
+		t.Error("Wait ignored a cancelled context")
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := newCircuitBreaker("test.rs", 0, time.Minute)
+	for i := 0; i < 10; i++ {
+		if b.Failure() {
+			t.Fatal("disabled breaker gave up")
+		}
+	}
+	if b.State() != BreakerClosed {
+		t.Errorf("disabled breaker opened: state %d", b.State())
+	}
+}
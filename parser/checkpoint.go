@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// checkpointMaxAge is how long an interrupted run stays resumable. Older
+// checkpoints are ignored and the site starts over from page 1.
+const checkpointMaxAge = 48 * time.Hour
+
+// Checkpoint records how far an unfinished run of a site got. It is written
+// after every saved page and removed when the site's run ends, so one left
+// behind means the process stopped mid-run.
+type Checkpoint struct {
+	Site         string
+	RunID        string
+	RunStartedAt time.Time
+	LastPage     int
+	ItemsSoFar   int
+	TotalItems   int
+	SaveFailed   bool
+	UpdatedAt    time.Time
+}
+
+// resumable reports whether a run should continue from cp. A stale
+// checkpoint would resume a listing that has shifted too much since.
+func (cp *Checkpoint) resumable(now time.Time) bool {
+	return cp != nil && cp.LastPage > 0 && now.Sub(cp.UpdatedAt) < checkpointMaxAge
+}
+
+// freshRunForced reports whether PARSER_FRESH_RUN asks to drop checkpoints at
+// startup and scrape every site from page 1.
+func freshRunForced() bool {
+	return os.Getenv("PARSER_FRESH_RUN") == "true"
+}
+
+// checkpointsCommand implements "parser checkpoints list|clear".
+func checkpointsCommand(s *Storage, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: checkpoints list | checkpoints clear [-site SITE]")
+	}
+
+	switch args[0] {
+	case "list":
+		checkpoints, err := s.ListCheckpoints(context.Background())
+		if err != nil {
+			return err
+		}
+		return printCheckpoints(os.Stdout, checkpoints)
+	case "clear":
+		fs := flag.NewFlagSet("checkpoints clear", flag.ContinueOnError)
+		site := fs.String("site", "", "only this site, e.g. 4zida.rs/rent")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		count, err := s.ClearCheckpoints(context.Background(), *site)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("cleared %d checkpoint(s)\n", count)
+		return nil
+	default:
+		return fmt.Errorf("unknown checkpoints command %q", args[0])
+	}
+}
+
+func printCheckpoints(w io.Writer, checkpoints []Checkpoint) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SITE\tRUN\tLAST PAGE\tITEMS\tTOTAL\tUPDATED")
+	for _, cp := range checkpoints {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\n",
+			cp.Site, cp.RunID, cp.LastPage, cp.ItemsSoFar, cp.TotalItems, cp.UpdatedAt.Format(time.DateTime))
+	}
+	return tw.Flush()
+}
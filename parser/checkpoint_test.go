@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestCheckpointResumable(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name string
+		cp   *Checkpoint
+		want bool
+	}{
+		{"no checkpoint", nil, false},
+		{"restarted an hour ago", &Checkpoint{LastPage: 12, UpdatedAt: now.Add(-time.Hour)}, true},
+		{"too old", &Checkpoint{LastPage: 12, UpdatedAt: now.Add(-checkpointMaxAge)}, false},
+		{"no page saved", &Checkpoint{LastPage: 0, UpdatedAt: now}, false},
+	}
+
+	for _, c := range cases {
+		if got := c.cp.resumable(now); got != c.want {
+			t.Errorf("%s: resumable = %v; want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestPrintCheckpoints(t *testing.T) {
+	checkpoints := []Checkpoint{{
+		Site:       "cityexpert.rs",
+		RunID:      "20261017T040000Z",
+		LastPage:   7,
+		ItemsSoFar: 140,
+		TotalItems: 596,
+		UpdatedAt:  time.Date(2026, 10, 17, 4, 20, 0, 0, time.UTC),
+	}}
+
+	var buf bytes.Buffer
+	if err := printCheckpoints(&buf, checkpoints); err != nil {
+		t.Fatal(err)
+	}
+	want := "SITE           RUN               LAST PAGE  ITEMS  TOTAL  UPDATED\n" +
+		"cityexpert.rs  20261017T040000Z  7          140    596    2026-10-17 04:20:00\n"
+	if buf.String() != want {
+		t.Errorf("checkpoints =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const usage = `usage: parser [command] [flags]
+
+Commands:
+  run                           scrape each site on its own cron schedule, overdue
+                                sites right away (default)
+  once                          scrape every site once and exit
+  scrape -site NAME [-pages R]  scrape a page range of one site into the database
+  dry-run [-site NAME] [-pages R]
+                                parse pages and print the cards as NDJSON, no database
+  migrate status|up|down        manage the database schema
+  reparse                       re-parse archived card HTML
+  quarantine list|release       inspect estates that failed validation
+  checkpoints list|clear        inspect or drop checkpoints of unfinished runs
+
+Sites are named like 4zida.rs or halooglasi.com/rent. Page ranges look like
+1-3 or 5.`
+
+// parsePageRange parses "3" or "1-3" into an inclusive range of pages.
+func parsePageRange(raw string) (first, last int, err error) {
+	from, to, isRange := strings.Cut(raw, "-")
+	first, err = strconv.Atoi(strings.TrimSpace(from))
+	if err != nil || first < 1 {
+		return 0, 0, fmt.Errorf("invalid page range %q", raw)
+	}
+	if !isRange {
+		return first, first, nil
+	}
+
+	last, err = strconv.Atoi(strings.TrimSpace(to))
+	if err != nil || last < first {
+		return 0, 0, fmt.Errorf("invalid page range %q", raw)
+	}
+	return first, last, nil
+}
+
+// pageFlags parses the -site and -pages flags shared by scrape and dry-run.
+func pageFlags(name string, args []string) (site string, first, last int, err error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	siteName := fs.String("site", "", "site name, e.g. halooglasi.com or 4zida.rs/rent")
+	pages := fs.String("pages", "1", "page or inclusive page range, e.g. 1-3")
+	if err := fs.Parse(args); err != nil {
+		return "", 0, 0, err
+	}
+
+	first, last, err = parsePageRange(*pages)
+	return *siteName, first, last, err
+}
+
+// scrapeCommand implements "parser scrape -site NAME -pages 1-3": it saves a
+// page range of one site like a regular run would, but never delists since
+// the rest of the listing was not seen.
+func scrapeCommand(ctx context.Context, s *Storage, sites []Site, args []string) error {
+	name, first, last, err := pageFlags("scrape", args)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return errors.New("usage: scrape -site NAME [-pages 1-3]")
+	}
+	site, ok := siteByName(sites, name)
+	if !ok {
+		return fmt.Errorf("unknown site %q", name)
+	}
+
+	start := time.Now()
+	run := newSiteRun(start.UTC().Format("20060102T150405Z"), site, start)
+	run.Mode = RunModeRange
+	withDetails := detailsEnabled()
+	termination := TerminationPageRange
+	fetcher := newPageFetcher(ctx, site, fetchPolicy)
+	defer fetcher.drop()
+	fetcher.limit(last)
+
+	for page := first; page <= last; page++ {
+		if ctx.Err() != nil {
+			termination = stopReason(ctx)
+			break
+		}
+		estates, _, err := fetcher.get(page)
+		if err != nil && ctx.Err() != nil {
+			termination = stopReason(ctx)
+			break
+		}
+		if err != nil {
+			slog.Error("Error parsing", "site", name, "page", page, "error", err)
+			run.countError("list_fetch")
+			termination = TerminationFetchError
+			break
+		}
+		run.Pages++
+		if len(estates) == 0 {
+			termination = TerminationCompleted
+			break
+		}
+
+		pageFill := newFillStats()
+		pageFill.add(estates)
+		if low := pageFill.belowThresholds(site.Spec.MinFillRates); len(low) > 0 {
+			slog.Error("Fill rates collapsed, site degraded", "site", name, "page", page, "fields", low)
+			run.countItems("skipped", len(estates))
+			termination = TerminationDegraded
+			break
+		}
+
+		saved, _ := savePage(ctx, s, site, run, page, estates, withDetails)
+		slog.Info("Saved page", "site", name, "page", page, "count", len(saved))
+	}
+
+	run.finish(termination, time.Now())
+	if err := s.SaveSiteRun(context.WithoutCancel(ctx), run); err != nil {
+		return err
+	}
+	if ctx.Err() == nil {
+		runDeduplication(ctx, s)
+	}
+
+	fmt.Printf("%s pages %d-%d: %d inserted, %d updated, %d unchanged, %d failed, %d quarantined (%s)\n",
+		name, first, last, run.ItemsInserted, run.ItemsUpdated, run.ItemsUnchanged, run.ItemsFailed,
+		run.ItemsQuarantined, termination)
+	if run.ItemsFailed > 0 || (termination != TerminationPageRange && termination != TerminationCompleted) {
+		return fmt.Errorf("scrape of %s ended with %s", name, termination)
+	}
+	return nil
+}
+
+// dryRunCommand implements "parser dry-run [-site NAME] [-pages 1-3]": it
+// parses listing pages and writes every card, priced or not, to w as one JSON
+// object per line. Nothing is saved; failed validation rules and collapsed
+// fill rates are only logged.
+func dryRunCommand(ctx context.Context, sites []Site, args []string, w io.Writer) error {
+	name, first, last, err := pageFlags("dry-run", args)
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		site, ok := siteByName(sites, name)
+		if !ok {
+			return fmt.Errorf("unknown site %q", name)
+		}
+		sites = []Site{site}
+	}
+
+	enc := json.NewEncoder(w)
+	failed := false
+	for _, site := range sites {
+		fetcher := newPageFetcher(ctx, site, fetchPolicy)
+		fetcher.limit(last)
+		for page := first; page <= last; page++ {
+			estates, info, err := fetcher.get(page)
+			if err != nil && ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				slog.Error("Error parsing", "site", site.Name, "page", page, "error", err)
+				failed = true
+				break
+			}
+			if len(estates) == 0 {
+				break
+			}
+
+			pageFill := newFillStats()
+			pageFill.add(estates)
+			slog.Info("Parsed page", "site", site.Name, "page", page, "count", len(estates), "total_found", info.TotalItems, "total_pages", info.TotalPages,
+				"below_fill_rates", pageFill.belowThresholds(site.Spec.MinFillRates))
+
+			for _, e := range estates {
+				if rules := validateEstate(e); len(rules) > 0 {
+					slog.Warn("Estate fails validation", "site", site.Name, "link", e.Link, "rules", rules)
+				}
+				e.RawHTML = ""
+				if err := enc.Encode(e); err != nil {
+					return fmt.Errorf("failed to write estate: %w", err)
+				}
+			}
+		}
+		fetcher.drop()
+	}
+
+	if failed {
+		return errors.New("some pages could not be fetched")
+	}
+	return nil
+}
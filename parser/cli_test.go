@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParsePageRange(t *testing.T) {
+	cases := []struct {
+		raw         string
+		first, last int
+	}{
+		{"1", 1, 1},
+		{"5", 5, 5},
+		{"1-3", 1, 3},
+		{" 2 - 4 ", 2, 4},
+		{"3-3", 3, 3},
+	}
+	for _, c := range cases {
+		first, last, err := parsePageRange(c.raw)
+		if err != nil {
+			t.Errorf("parsePageRange(%q): %v", c.raw, err)
+			continue
+		}
+		if first != c.first || last != c.last {
+			t.Errorf("parsePageRange(%q) = %d-%d; want %d-%d", c.raw, first, last, c.first, c.last)
+		}
+	}
+
+	for _, raw := range []string{"", "0", "-1", "3-1", "1-", "a-b", "1-3-5"} {
+		if _, _, err := parsePageRange(raw); err == nil {
+			t.Errorf("parsePageRange(%q): expected an error", raw)
+		}
+	}
+}
+
+func TestDryRunCommand(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := OpenHTTPArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := os.ReadFile(filepath.Join("testdata", "4zida_list.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	site := testSite(t, "4zida.rs")
+	err = archive.Save(ArchivedResponse{
+		Method:     http.MethodGet,
+		URL:        site.URLs.StartURL,
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:       body,
+		FetchedAt:  time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := configureHTTPMode(HTTPModeReplay, dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { configureHTTPMode(HTTPModeLive, "") })
+
+	sites := []Site{site}
+	var out bytes.Buffer
+	if err := dryRunCommand(context.Background(), sites, []string{"-site", "4zida.rs", "-pages", "1"}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	var estates []RealEstate
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var e RealEstate
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+		estates = append(estates, e)
+	}
+	if len(estates) != 2 {
+		t.Fatalf("got %d lines; want 2", len(estates))
+	}
+	if estates[0].Price != 150000 || estates[0].Link == "" || estates[0].RawHTML != "" {
+		t.Errorf("unexpected first estate %+v", estates[0])
+	}
+
+	// Page 2 was never recorded; the cards of page 1 are still written.
+	out.Reset()
+	if err := dryRunCommand(context.Background(), sites, []string{"-pages", "1-2"}, &out); err == nil {
+		t.Error("expected an error for the unfetchable page")
+	}
+	if lines := bytes.Count(out.Bytes(), []byte("\n")); lines != 2 {
+		t.Errorf("got %d lines; want 2", lines)
+	}
+
+	if err := dryRunCommand(context.Background(), sites, []string{"-site", "nosuch.rs"}, &out); err == nil {
+		t.Error("expected an error for an unknown site")
+	}
+	if err := dryRunCommand(context.Background(), sites, []string{"-pages", "2-1"}, &out); err == nil {
+		t.Error("expected an error for an invalid page range")
+	}
+}
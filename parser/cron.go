@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMacros are the shorthands accepted in place of five fields.
+var cronMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// CronSchedule is a parsed five-field cron expression: minute, hour, day of
+// month, month and day of week (0-7, both 0 and 7 being Sunday). Fields take
+// numbers, "*", ranges "a-b", steps "*/n" or "a-b/n" and comma separated
+// lists of those. As in cron, a day matches either day field when both are
+// restricted.
+type CronSchedule struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64
+	domRestricted, dowRestricted  bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseCron parses a cron expression such as "30 3 * * 1" or "@daily".
+func ParseCron(expr string) (CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) == 1 {
+		if macro, ok := cronMacros[fields[0]]; ok {
+			fields = strings.Fields(macro)
+		}
+	}
+	if len(fields) != len(cronFields) {
+		return CronSchedule{}, fmt.Errorf("cron expression %q needs 5 fields", expr)
+	}
+
+	var bits [5]uint64
+	for i, f := range cronFields {
+		var err error
+		bits[i], err = parseCronField(fields[i], f)
+		if err != nil {
+			return CronSchedule{}, fmt.Errorf("cron expression %q: %w", expr, err)
+		}
+	}
+
+	c := CronSchedule{
+		expr:          strings.Join(fields, " "),
+		minute:        bits[0],
+		hour:          bits[1],
+		dom:           bits[2],
+		month:         bits[3],
+		dow:           bits[4],
+		domRestricted: !strings.HasPrefix(fields[2], "*"),
+		dowRestricted: !strings.HasPrefix(fields[4], "*"),
+	}
+	// Sunday may be written as 7.
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+
+	if c.Next(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return CronSchedule{}, fmt.Errorf("cron expression %q never fires", expr)
+	}
+	return c, nil
+}
+
+func parseCronField(raw string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(raw, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%s: invalid step in %q", f.name, part)
+			}
+			step = n
+		}
+
+		first, last := f.min, f.max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if first, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("%s: invalid value in %q", f.name, part)
+			}
+			last = first
+			if isRange {
+				if last, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("%s: invalid value in %q", f.name, part)
+				}
+			} else if hasStep {
+				last = f.max
+			}
+		}
+		if first < f.min || last > f.max || first > last {
+			return 0, fmt.Errorf("%s: %q is outside %d-%d", f.name, part, f.min, f.max)
+		}
+
+		for v := first; v <= last; v += step {
+			bits |= 1 << v
+		}
+	}
+	if bits == 0 {
+		return 0, errors.New(f.name + ": no values")
+	}
+	return bits, nil
+}
+
+// Next returns the first time after t, to the minute, that matches the
+// schedule in t's location, or the zero time if there is none within five
+// years.
+func (c CronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<int(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.hour&(1<<t.Hour()) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if c.minute&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (c CronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+func (c CronSchedule) String() string {
+	return c.expr
+}
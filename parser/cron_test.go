@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// 2026-10-17 is a Saturday.
+	at := time.Date(2026, 10, 17, 12, 30, 45, 0, time.UTC)
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 10, 17, 12, 31, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2026, 10, 18, 2, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"45 12 * * *", time.Date(2026, 10, 17, 12, 45, 0, 0, time.UTC)},
+		{"*/20 * * * *", time.Date(2026, 10, 17, 12, 40, 0, 0, time.UTC)},
+		{"0 4 * * 1", time.Date(2026, 10, 19, 4, 0, 0, 0, time.UTC)},
+		{"0 4 * * 7", time.Date(2026, 10, 18, 4, 0, 0, 0, time.UTC)},
+		{"0 3 */2 * *", time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either one matches.
+		{"0 0 1 * 0", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0,12 20 * 5", time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		schedule, err := ParseCron(c.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", c.expr, err)
+			continue
+		}
+		if got := schedule.Next(at); !got.Equal(c.want) {
+			t.Errorf("%q: Next = %v; want %v", c.expr, got, c.want)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"0 2 * *",
+		"@yearly",
+		"60 * * * *",
+		"0 24 * * *",
+		"0 0 0 * *",
+		"0 0 * 13 *",
+		"0 0 * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"0 0 31 2 *",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q): expected an error", expr)
+		}
+	}
+}
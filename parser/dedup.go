@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Tolerances used to decide whether two listings from different portals
+// describe the same apartment.
+const (
+	dedupSqmTolerance      = 0.03 // relative difference in square meters
+	dedupMinSqmTolerance   = 2    // absolute slack for small apartments
+	dedupPriceTolerance    = 0.05 // relative difference in price
+	dedupMatchThreshold    = 0.75
+	dedupSqmBucketWidth    = 5
+	dedupUnknownFloorLimit = -5
+)
+
+type dedupCandidate struct {
+	ID           int64
+	ClusterID    int64 // 0 when the listing has not been clustered yet
+	Source       string
+	ListingType  string
+	Price        int32
+	Currency     string
+	SquareMeter  int32
+	QuantityRoom float32
+	Floor        float32
+	FloorTotal   float32
+	Location     string
+}
+
+// similarity scores how likely two listings are the same apartment, from 0
+// to 1. Listings with a different listing type, currency, clearly different
+// area or known locations without a word in common never match; the
+// remaining features are weighted by how reliably portals report them.
+func similarity(a, b dedupCandidate) float64 {
+	if a.ListingType != b.ListingType || a.Currency != b.Currency || a.SquareMeter <= 0 || b.SquareMeter <= 0 {
+		return 0
+	}
+
+	// Equal numbers are common among new builds, so two listings in
+	// different districts would otherwise match on them alone.
+	aLoc, bLoc := locationTokens(a.Location), locationTokens(b.Location)
+	locationScore := tokenOverlap(aLoc, bLoc)
+	if len(aLoc) > 0 && len(bLoc) > 0 && locationScore == 0 {
+		return 0
+	}
+
+	sqmDiff := math.Abs(float64(a.SquareMeter - b.SquareMeter))
+	sqmTol := math.Max(dedupMinSqmTolerance, dedupSqmTolerance*math.Max(float64(a.SquareMeter), float64(b.SquareMeter)))
+	if sqmDiff > sqmTol {
+		return 0
+	}
+	sqmScore := 1 - sqmDiff/(sqmTol*2)
+
+	priceScore := 0.0
+	if a.Price > 0 && b.Price > 0 {
+		rel := math.Abs(float64(a.Price-b.Price)) / math.Max(float64(a.Price), float64(b.Price))
+		if rel <= dedupPriceTolerance {
+			priceScore = 1 - rel/(dedupPriceTolerance*2)
+		}
+	}
+
+	roomsScore := 0.5
+	if a.QuantityRoom > 0 && b.QuantityRoom > 0 {
+		switch diff := math.Abs(float64(a.QuantityRoom - b.QuantityRoom)); {
+		case diff == 0:
+			roomsScore = 1
+		case diff <= 0.5:
+			roomsScore = 0.5
+		default:
+			roomsScore = 0
+		}
+	}
+
+	floorScore := 0.5
+	if floorKnown(a) && floorKnown(b) {
+		floorScore = (floorMatch(a.Floor, b.Floor) + floorMatch(a.FloorTotal, b.FloorTotal)) / 2
+	}
+	return 0.3*sqmScore + 0.3*priceScore + 0.1*roomsScore + 0.15*floorScore + 0.15*locationScore
+}
+
+// floorKnown reports whether the portal reported a floor at all; cards
+// without floor information leave both values at zero.
+func floorKnown(c dedupCandidate) bool {
+	return c.Floor != 0 || c.FloorTotal != 0
+}
+
+func floorMatch(a, b float32) float64 {
+	if a <= dedupUnknownFloorLimit || b <= dedupUnknownFloorLimit {
+		return 0.5
+	}
+	if a == b {
+		return 1
+	}
+	return 0
+}
+
+var locationStopWords = map[string]bool{
+	"beograd": true, "srbija": true, "opstina": true, "grad": true,
+}
+
+var diacriticsReplacer = strings.NewReplacer(
+	"č", "c", "ć", "c", "š", "s", "ž", "z", "đ", "dj",
+)
+
+// locationTokens normalizes a free-form location into a set of lowercase
+// ASCII words, so "Vračar, Beograd" and "vracar" compare equal.
+func locationTokens(location string) map[string]bool {
+	normalized := diacriticsReplacer.Replace(strings.ToLower(location))
+	words := strings.FieldsFunc(normalized, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := make(map[string]bool, len(words))
+	for _, w := range words {
+		if !locationStopWords[w] {
+			tokens[w] = true
+		}
+	}
+	return tokens
+}
+
+func tokenOverlap(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range a {
+		if b[t] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// clusterListings groups matching listings from different sources and
+// returns the cluster id of every candidate, reusing ids assigned in earlier
+// runs so they stay stable.
+func clusterListings(candidates []dedupCandidate) map[int64]int64 {
+	parent := make([]int, len(candidates))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	buckets := make(map[int32][]int)
+	for i, c := range candidates {
+		if c.SquareMeter > 0 {
+			key := c.SquareMeter / dedupSqmBucketWidth
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	for i, a := range candidates {
+		if a.SquareMeter <= 0 {
+			continue
+		}
+		key := a.SquareMeter / dedupSqmBucketWidth
+		for k := key - 1; k <= key+1; k++ {
+			for _, j := range buckets[k] {
+				if j <= i {
+					continue
+				}
+				b := candidates[j]
+				if a.Source == b.Source {
+					continue
+				}
+				if similarity(a, b) >= dedupMatchThreshold {
+					parent[find(i)] = find(j)
+				}
+			}
+		}
+	}
+
+	indexByID := make(map[int64]int, len(candidates))
+	components := make(map[int][]int)
+	var roots []int
+	for i, c := range candidates {
+		indexByID[c.ID] = i
+		root := find(i)
+		if _, ok := components[root]; !ok {
+			roots = append(roots, root)
+		}
+		components[root] = append(components[root], i)
+	}
+
+	// A cluster id is the estate id of its founding member. A component keeps
+	// the id of a founder it still contains; if the founder is gone (delisted)
+	// the first component claiming the id keeps it. Otherwise the smallest
+	// member id founds a new cluster, which can never collide with another.
+	claimed := make(map[int64]bool)
+	clusterOf := make(map[int]int64, len(components))
+	for _, root := range roots {
+		var founder, orphan, smallest int64
+		for _, i := range components[root] {
+			c := candidates[i]
+			if smallest == 0 || c.ID < smallest {
+				smallest = c.ID
+			}
+			if c.ClusterID == 0 {
+				continue
+			}
+			if j, ok := indexByID[c.ClusterID]; ok {
+				if find(j) == root && (founder == 0 || c.ClusterID < founder) {
+					founder = c.ClusterID
+				}
+			} else if !claimed[c.ClusterID] && (orphan == 0 || c.ClusterID < orphan) {
+				orphan = c.ClusterID
+			}
+		}
+
+		switch {
+		case founder != 0:
+			clusterOf[root] = founder
+		case orphan != 0:
+			clusterOf[root] = orphan
+			claimed[orphan] = true
+		default:
+			clusterOf[root] = smallest
+		}
+	}
+
+	assignments := make(map[int64]int64, len(candidates))
+	for i, c := range candidates {
+		assignments[c.ID] = clusterOf[find(i)]
+	}
+	return assignments
+}
+
+// runDeduplication clusters all active listings and stores cluster ids that
+// changed since the previous run.
+func runDeduplication(ctx context.Context, s *Storage) {
+	candidates, err := s.LoadDedupCandidates(ctx)
+	if err != nil {
+		slog.Error("Failed to load deduplication candidates", "error", err)
+		parserErrors.WithLabelValues("all", "dedup").Inc()
+		return
+	}
+
+	assignments := clusterListings(candidates)
+
+	changed := make(map[int64]int64)
+	members := make(map[int64]int)
+	for _, c := range candidates {
+		clusterID := assignments[c.ID]
+		members[clusterID]++
+		if clusterID != c.ClusterID {
+			changed[c.ID] = clusterID
+		}
+	}
+
+	duplicates := 0
+	for _, count := range members {
+		duplicates += count - 1
+	}
+	dedupDuplicates.Set(float64(duplicates))
+
+	if err := s.SaveClusters(ctx, changed); err != nil {
+		slog.Error("Failed to save clusters", "error", err)
+		parserErrors.WithLabelValues("all", "dedup").Inc()
+		return
+	}
+
+	slog.Info("Deduplication completed", "listings", len(candidates), "clusters", len(members), "duplicates", duplicates, "changed", len(changed))
+}
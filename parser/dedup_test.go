@@ -0,0 +1,148 @@
+package main
+
+import "testing"
+
+func TestSimilarity(t *testing.T) {
+	base := dedupCandidate{
+		ID: 1, Source: "4zida.rs", Price: 150000, Currency: "EUR", SquareMeter: 60,
+		QuantityRoom: 2.5, Floor: 3, FloorTotal: 5, Location: "Kalenić pijaca, Vračar, Beograd",
+	}
+
+	cases := []struct {
+		name  string
+		other dedupCandidate
+		match bool
+	}{
+		{
+			name: "same apartment on another portal",
+			other: dedupCandidate{
+				ID: 2, Source: "halooglasi.com", Price: 149000, Currency: "EUR", SquareMeter: 61,
+				QuantityRoom: 2.5, Floor: 3, FloorTotal: 5, Location: "Beograd, Vračar, Kalenic pijaca",
+			},
+			match: true,
+		},
+		{
+			name: "portal without floor information",
+			other: dedupCandidate{
+				ID: 3, Source: "nekretnine.rs", Price: 150000, Currency: "EUR", SquareMeter: 60,
+				QuantityRoom: 2.5, Location: "Vračar, Beograd",
+			},
+			match: true,
+		},
+		{
+			name: "different area",
+			other: dedupCandidate{
+				ID: 4, Source: "halooglasi.com", Price: 150000, Currency: "EUR", SquareMeter: 70,
+				QuantityRoom: 2.5, Floor: 3, FloorTotal: 5, Location: "Kalenić pijaca, Vračar, Beograd",
+			},
+			match: false,
+		},
+		{
+			name: "different price and floor",
+			other: dedupCandidate{
+				ID: 5, Source: "halooglasi.com", Price: 175000, Currency: "EUR", SquareMeter: 60,
+				QuantityRoom: 2.5, Floor: 1, FloorTotal: 5, Location: "Zvezdara, Beograd",
+			},
+			match: false,
+		},
+		{
+			name: "same numbers in another district",
+			other: dedupCandidate{
+				ID: 8, Source: "halooglasi.com", Price: 150000, Currency: "EUR", SquareMeter: 60,
+				QuantityRoom: 2.5, Floor: 3, FloorTotal: 5, Location: "Zvezdara, Beograd",
+			},
+			match: false,
+		},
+		{
+			name: "rent listing of the same apartment",
+			other: dedupCandidate{
+				ID: 7, Source: "halooglasi.com", ListingType: ListingRent, Price: 150000, Currency: "EUR", SquareMeter: 60,
+				QuantityRoom: 2.5, Floor: 3, FloorTotal: 5, Location: "Kalenić pijaca, Vračar, Beograd",
+			},
+			match: false,
+		},
+		{
+			name: "different currency",
+			other: dedupCandidate{
+				ID: 6, Source: "halooglasi.com", Price: 150000, Currency: "RSD", SquareMeter: 60,
+				QuantityRoom: 2.5, Floor: 3, FloorTotal: 5, Location: "Kalenić pijaca, Vračar, Beograd",
+			},
+			match: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			score := similarity(base, c.other)
+			if got := score >= dedupMatchThreshold; got != c.match {
+				t.Errorf("similarity = %.3f, match = %v; want match = %v", score, got, c.match)
+			}
+		})
+	}
+}
+
+func TestClusterListingsStableIDs(t *testing.T) {
+	candidates := []dedupCandidate{
+		{ID: 10, ClusterID: 10, Source: "4zida.rs", Price: 100000, Currency: "EUR", SquareMeter: 50, QuantityRoom: 2, Location: "Zemun"},
+		{ID: 20, ClusterID: 10, Source: "halooglasi.com", Price: 100000, Currency: "EUR", SquareMeter: 50, QuantityRoom: 2, Location: "Zemun"},
+		{ID: 5, Source: "nekretnine.rs", Price: 101000, Currency: "EUR", SquareMeter: 50, QuantityRoom: 2, Location: "Zemun, Beograd"},
+		{ID: 30, Source: "4zida.rs", Price: 100000, Currency: "EUR", SquareMeter: 50, QuantityRoom: 2, Location: "Zemun"},
+		{ID: 40, Source: "cityexpert.rs", Price: 300000, Currency: "EUR", SquareMeter: 120, QuantityRoom: 4, Location: "Dorćol"},
+	}
+
+	clusters := clusterListings(candidates)
+
+	// The new nekretnine listing joins the existing cluster, which keeps its id
+	// even though the newcomer has a smaller estate id.
+	for _, id := range []int64{10, 20, 5, 30} {
+		if clusters[id] != 10 {
+			t.Errorf("cluster of %d = %d, want 10", id, clusters[id])
+		}
+	}
+	if clusters[40] != 40 {
+		t.Errorf("cluster of 40 = %d, want its own id", clusters[40])
+	}
+}
+
+func TestClusterListingsDistricts(t *testing.T) {
+	// Identical numbers in two districts must neither match nor chain the
+	// clusters of their districts together.
+	candidates := []dedupCandidate{
+		{ID: 1, Source: "4zida.rs", Price: 120000, Currency: "EUR", SquareMeter: 55, QuantityRoom: 2, Floor: 2, FloorTotal: 6, Location: "Vračar, Beograd"},
+		{ID: 2, Source: "halooglasi.com", Price: 120000, Currency: "EUR", SquareMeter: 55, QuantityRoom: 2, Floor: 2, FloorTotal: 6, Location: "Zemun, Beograd"},
+		{ID: 3, Source: "nekretnine.rs", Price: 120000, Currency: "EUR", SquareMeter: 55, QuantityRoom: 2, Floor: 2, FloorTotal: 6, Location: "Zemun"},
+	}
+
+	clusters := clusterListings(candidates)
+	if clusters[1] != 1 {
+		t.Errorf("cluster of 1 = %d, want its own id", clusters[1])
+	}
+	if clusters[2] != 2 || clusters[3] != 2 {
+		t.Errorf("clusters of 2 and 3 = %d, %d, want 2", clusters[2], clusters[3])
+	}
+}
+
+func TestLocationTokens(t *testing.T) {
+	a := locationTokens("Kalenić pijaca, Vračar, Beograd")
+	b := locationTokens("vracar kalenic pijaca")
+	if got := tokenOverlap(a, b); got != 1 {
+		t.Errorf("tokenOverlap = %v, want 1", got)
+	}
+}
+
+func TestClusterListingsSplit(t *testing.T) {
+	// Listing 2 was clustered with 1 but its price changed since; it must
+	// leave the cluster without taking the id of listing 1 along.
+	candidates := []dedupCandidate{
+		{ID: 1, ClusterID: 1, Source: "4zida.rs", Price: 100000, Currency: "EUR", SquareMeter: 50, Location: "Zemun"},
+		{ID: 2, ClusterID: 1, Source: "halooglasi.com", Price: 140000, Currency: "EUR", SquareMeter: 50, Location: "Dorćol"},
+	}
+
+	clusters := clusterListings(candidates)
+	if clusters[1] != 1 {
+		t.Errorf("cluster of 1 = %d, want 1", clusters[1])
+	}
+	if clusters[2] != 2 {
+		t.Errorf("cluster of 2 = %d, want 2", clusters[2])
+	}
+}
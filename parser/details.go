@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// EstateAttributes holds listing attributes that are only available on the
+// detail page of a listing. Pointer fields stay nil when the page does not
+// mention the attribute, so "unknown" is not confused with "no".
+type EstateAttributes struct {
+	Heating    string `json:"heating,omitempty"`
+	YearBuilt  int    `json:"year_built,omitempty"`
+	Condition  string `json:"condition,omitempty"`
+	Elevator   *bool  `json:"elevator,omitempty"`
+	Parking    *bool  `json:"parking,omitempty"`
+	Terrace    *bool  `json:"terrace,omitempty"`
+	Registered *bool  `json:"registered,omitempty"`
+}
+
+func (a EstateAttributes) IsEmpty() bool {
+	return a == EstateAttributes{}
+}
+
+// DetailSelector describes one list of attribute rows on a detail page.
+// When Label and Value are empty the row text is used as "label: value",
+// or as a flag (e.g. "Lift") when there is no colon.
+type DetailSelector struct {
+	Item  string `json:"item"`
+	Label string `json:"label,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+func detailsEnabled() bool {
+	return os.Getenv("PARSE_DETAILS") == "true"
+}
+
+func parseDetailPage(ctx context.Context, domen string, link string, selectors []DetailSelector) (EstateAttributes, error) {
+	parser := setupParser(ctx)
+	var parsingError error
+	var attrs EstateAttributes
+
+	if link == "" {
+		return attrs, errors.New("detail link is empty")
+	}
+
+	parser.OnResponse(func(r *colly.Response) {
+		if r.StatusCode != 200 {
+			slog.Error("detail request failed for", "domen", domen, "status code", r.StatusCode, "url", r.Request.URL)
+			parsingError = fmt.Errorf("detail request failed for %s: %d %s", domen, r.StatusCode, r.Request.URL)
+		}
+	})
+
+	parser.OnHTML("html", func(e *colly.HTMLElement) {
+		attrs = extractDetails(e, selectors)
+	})
+
+	if err := parser.Visit(link); err != nil {
+		slog.Error("detail visit failed for", "domen", domen, "url", link, "error", err)
+		return attrs, err
+	}
+
+	if parsingError != nil {
+		return attrs, parsingError
+	}
+
+	slog.Debug("detail page parsed", "domain", domen, "link", link, "attributes", attrs)
+	return attrs, nil
+}
+
+func extractDetails(e *colly.HTMLElement, selectors []DetailSelector) EstateAttributes {
+	var attrs EstateAttributes
+	for _, sel := range selectors {
+		e.ForEach(sel.Item, func(_ int, el *colly.HTMLElement) {
+			var label, value string
+			if sel.Label != "" {
+				label = el.ChildText(sel.Label)
+				if sel.Value != "" {
+					value = el.ChildText(sel.Value)
+				}
+			} else {
+				label, value, _ = strings.Cut(strings.TrimSpace(el.Text), ":")
+			}
+			applyDetailAttribute(strings.TrimSpace(label), strings.TrimSpace(value), &attrs)
+		})
+	}
+	return attrs
+}
+
+func applyDetailAttribute(label string, value string, attrs *EstateAttributes) {
+	key := strings.ToLower(label)
+
+	switch {
+	case strings.Contains(key, "grejanje"):
+		attrs.Heating = value
+	case strings.Contains(key, "godina izgradnje"):
+		attrs.YearBuilt = parseYear(value)
+	case strings.Contains(key, "uknjižen"), strings.Contains(key, "uknjizen"):
+		attrs.Registered = boolPtr(parseYesNo(key + " " + value))
+	case strings.Contains(key, "stanje"):
+		attrs.Condition = value
+	case strings.Contains(key, "lift"):
+		attrs.Elevator = boolPtr(parseYesNo(key + " " + value))
+	case strings.Contains(key, "parking"), strings.Contains(key, "garaž"), strings.Contains(key, "garaz"):
+		attrs.Parking = boolPtr(parseYesNo(key + " " + value))
+	case strings.Contains(key, "teras"), strings.Contains(key, "lođa"), strings.Contains(key, "lodja"), strings.Contains(key, "balkon"):
+		attrs.Terrace = boolPtr(parseYesNo(key + " " + value))
+	}
+}
+
+// parseYesNo treats an attribute as present unless it is explicitly negated
+// ("Lift: Ne", "Nije uknjižen", "Bez parkinga").
+func parseYesNo(s string) bool {
+	for _, word := range strings.Fields(strings.ToLower(s)) {
+		switch strings.Trim(word, ".,:;") {
+		case "ne", "nije", "nema", "bez", "no":
+			return false
+		}
+	}
+	return true
+}
+
+func parseYear(s string) int {
+	year := int(parseNumeric(strings.ReplaceAll(s, ".", " ")))
+	if year < 1800 || year > 2100 {
+		return 0
+	}
+	return year
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
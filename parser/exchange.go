@@ -0,0 +1,162 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExchangeRate is the number of currency units per 1 EUR on a date, as
+// published by the National Bank of Serbia ("1 EUR = 117.2 RSD").
+type ExchangeRate struct {
+	Date     time.Time `json:"-"`
+	Currency string    `json:"currency"`
+	Rate     float64   `json:"rate"`
+}
+
+// ExchangeRates answers "what was the rate on that day" with the most recent
+// rate published on or before the day.
+type ExchangeRates struct {
+	byCurrency map[string][]ExchangeRate // sorted by date
+}
+
+func NewExchangeRates(rates []ExchangeRate) *ExchangeRates {
+	r := &ExchangeRates{byCurrency: make(map[string][]ExchangeRate)}
+	for _, rate := range rates {
+		r.byCurrency[rate.Currency] = append(r.byCurrency[rate.Currency], rate)
+	}
+	for _, list := range r.byCurrency {
+		sort.Slice(list, func(i, j int) bool { return list[i].Date.Before(list[j].Date) })
+	}
+	return r
+}
+
+func (r *ExchangeRates) Rate(currency string, at time.Time) (float64, bool) {
+	if currency == "EUR" {
+		return 1, true
+	}
+	if r == nil {
+		return 0, false
+	}
+
+	list := r.byCurrency[currency]
+	day := truncateToDay(at)
+	i := sort.Search(len(list), func(i int) bool { return list[i].Date.After(day) })
+	if i == 0 {
+		return 0, false
+	}
+	return list[i-1].Rate, true
+}
+
+// ToEUR converts an amount in currency to whole euros, or returns NULL when
+// the currency is unknown or has no rate for that day yet.
+func (r *ExchangeRates) ToEUR(amount int32, currency string, at time.Time) sql.NullInt32 {
+	rate, ok := r.Rate(currency, at)
+	if !ok || rate <= 0 || amount <= 0 {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: int32(math.Round(float64(amount) / rate)), Valid: true}
+}
+
+func truncateToDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// LoadExchangeRatesFile reads rates from a CSV file with a
+// "date,currency,rate" header or from a JSON array of
+// {"date": "2026-01-31", "currency": "RSD", "rate": 117.2} objects.
+func LoadExchangeRatesFile(path string) ([]ExchangeRate, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open exchange rates: %w", err)
+	}
+	defer f.Close()
+
+	var rates []ExchangeRate
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		rates, err = parseExchangeRatesJSON(f)
+	} else {
+		rates, err = parseExchangeRatesCSV(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse exchange rates %s: %w", path, err)
+	}
+	return rates, nil
+}
+
+func parseExchangeRatesCSV(r io.Reader) ([]ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("file is empty")
+	}
+
+	var rates []ExchangeRate
+	for i, record := range records[1:] {
+		if len(record) != 3 {
+			return nil, fmt.Errorf("line %d: expected date,currency,rate", i+2)
+		}
+		rate, err := newExchangeRate(record[0], record[1], record[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+2, err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+func parseExchangeRatesJSON(r io.Reader) ([]ExchangeRate, error) {
+	var raw []struct {
+		Date     string  `json:"date"`
+		Currency string  `json:"currency"`
+		Rate     float64 `json:"rate"`
+	}
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	rates := make([]ExchangeRate, 0, len(raw))
+	for i, item := range raw {
+		rate, err := newExchangeRate(item.Date, item.Currency, strconv.FormatFloat(item.Rate, 'f', -1, 64))
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+func newExchangeRate(date, currency, rate string) (ExchangeRate, error) {
+	d, err := time.Parse(time.DateOnly, strings.TrimSpace(date))
+	if err != nil {
+		return ExchangeRate{}, fmt.Errorf("invalid date %q", date)
+	}
+
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" || currency == "EUR" {
+		return ExchangeRate{}, fmt.Errorf("invalid currency %q", currency)
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+	if err != nil || value <= 0 {
+		return ExchangeRate{}, fmt.Errorf("invalid rate %q", rate)
+	}
+	return ExchangeRate{Date: d, Currency: currency, Rate: value}, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	d, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestExchangeRatesLookup(t *testing.T) {
+	rates := NewExchangeRates([]ExchangeRate{
+		{Date: day("2026-02-01"), Currency: "RSD", Rate: 117.0},
+		{Date: day("2026-01-01"), Currency: "RSD", Rate: 117.5},
+	})
+
+	cases := []struct {
+		name     string
+		amount   int32
+		currency string
+		at       time.Time
+		want     sql.NullInt32
+	}{
+		{"euro passes through", 150000, "EUR", day("2020-01-01"), sql.NullInt32{Int32: 150000, Valid: true}},
+		{"rate of the day", 11750000, "RSD", day("2026-01-01"), sql.NullInt32{Int32: 100000, Valid: true}},
+		{"latest earlier rate", 11700000, "RSD", day("2026-03-15").Add(13 * time.Hour), sql.NullInt32{Int32: 100000, Valid: true}},
+		{"rounded", 100, "RSD", day("2026-01-20"), sql.NullInt32{Int32: 1, Valid: true}},
+		{"before first rate", 11750000, "RSD", day("2025-12-31"), sql.NullInt32{}},
+		{"unknown currency", 1000, "USD", day("2026-02-01"), sql.NullInt32{}},
+		{"no price", 0, "EUR", day("2026-02-01"), sql.NullInt32{}},
+	}
+
+	for _, c := range cases {
+		if got := rates.ToEUR(c.amount, c.currency, c.at); got != c.want {
+			t.Errorf("%s: ToEUR(%d %s) = %+v; want %+v", c.name, c.amount, c.currency, got, c.want)
+		}
+	}
+
+	var none *ExchangeRates
+	if got := none.ToEUR(1000, "RSD", day("2026-02-01")); got.Valid {
+		t.Errorf("nil rates converted RSD: %+v", got)
+	}
+}
+
+func TestLoadExchangeRatesFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"rates.csv":  "date,currency,rate\n# NBS middle rate\n2026-01-01,rsd,117.5\n2026-02-01, RSD, 117.0\n",
+		"rates.json": `[{"date": "2026-01-01", "currency": "RSD", "rate": 117.5}, {"date": "2026-02-01", "currency": "rsd", "rate": 117}]`,
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		rates, err := LoadExchangeRatesFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(rates) != 2 {
+			t.Fatalf("%s: loaded %d rates; want 2", name, len(rates))
+		}
+		if rates[1].Currency != "RSD" || rates[1].Rate != 117 || !rates[1].Date.Equal(day("2026-02-01")) {
+			t.Errorf("%s: second rate = %+v", name, rates[1])
+		}
+	}
+}
+
+func TestLoadExchangeRatesFileErrors(t *testing.T) {
+	cases := map[string]string{
+		"bad date":     "date,currency,rate\n01.02.2026,RSD,117\n",
+		"zero rate":    "date,currency,rate\n2026-02-01,RSD,0\n",
+		"euro rate":    "date,currency,rate\n2026-02-01,EUR,1\n",
+		"missing rate": "date,currency,rate\n2026-02-01,RSD\n",
+	}
+
+	dir := t.TempDir()
+	for name, content := range cases {
+		path := filepath.Join(dir, strings.ReplaceAll(name, " ", "_")+".csv")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadExchangeRatesFile(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import "context"
+
+// pageFetcher fetches the listing pages of a site ahead of its run, at most
+// FetchPolicy.Workers at a time, and hands them out in page order so the run
+// saves pages and checks its stop conditions as if it fetched them one by
+// one. Pages fetched past a stop condition are thrown away.
+type pageFetcher struct {
+	ctx     context.Context
+	site    Site
+	policy  FetchPolicy
+	last    int // last page worth fetching ahead, 0 when unknown
+	pending map[int]*pendingPage
+}
+
+// pendingPage is a page in flight. Its fields are set before done is closed.
+type pendingPage struct {
+	cancel  context.CancelFunc
+	done    chan struct{}
+	estates []RealEstate
+	info    PageInfo
+	err     error
+}
+
+func newPageFetcher(ctx context.Context, site Site, p FetchPolicy) *pageFetcher {
+	return &pageFetcher{
+		ctx:     ctx,
+		site:    site,
+		policy:  p,
+		pending: make(map[int]*pendingPage),
+	}
+}
+
+// limit stops fetching ahead past page last; 0 lifts the limit. Pages asked
+// for with get are fetched regardless.
+func (f *pageFetcher) limit(last int) {
+	f.last = last
+}
+
+// get returns a listing page, waiting for it if it is still in flight, and
+// starts fetching the pages after it.
+func (f *pageFetcher) get(page int) ([]RealEstate, PageInfo, error) {
+	for p := page; p < page+max(1, f.policy.Workers); p++ {
+		if p > page && f.last > 0 && p > f.last {
+			break
+		}
+		if _, ok := f.pending[p]; !ok {
+			f.start(p)
+		}
+	}
+
+	pp := f.pending[page]
+	delete(f.pending, page)
+	<-pp.done
+	pp.cancel()
+	return pp.estates, pp.info, pp.err
+}
+
+func (f *pageFetcher) start(page int) {
+	ctx, cancel := context.WithCancel(f.ctx)
+	pp := &pendingPage{cancel: cancel, done: make(chan struct{})}
+	f.pending[page] = pp
+
+	go func() {
+		defer close(pp.done)
+		pp.estates, pp.info, pp.err = listWithRetry(ctx, f.site, page, f.policy)
+	}()
+}
+
+// drop cancels the pages in flight and waits for their requests to abort,
+// so after a failed page or at the end of a run nothing more is fetched.
+// Dropped pages are fetched again if asked for.
+func (f *pageFetcher) drop() {
+	for _, pp := range f.pending {
+		pp.cancel()
+	}
+	for page, pp := range f.pending {
+		<-pp.done
+		delete(f.pending, page)
+	}
+}
+
+// plannedLastPage is the last page a run of a site is expected to need: the
+// spec's page limit, or the end of the listing when the strategy stops
+// there. It is 0 when unknown.
+func plannedLastPage(p PaginationSpec, plan crawlPlan) int {
+	last := 0
+	switch p.Strategy {
+	case PaginationPageCount:
+		last = plan.totalPages
+	case PaginationTotalCount:
+		last = plan.pages()
+	}
+	if p.MaxPages > 0 && (last == 0 || last > p.MaxPages) {
+		last = p.MaxPages
+	}
+	return last
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pagedTransport serves listing pages with one card linking to /oglas/N,
+// and an empty page after last. It records the pages requested and the
+// highest number of requests in flight.
+type pagedTransport struct {
+	last int
+
+	mu        sync.Mutex
+	requested []int
+	inFlight  int
+	maxFlight int
+}
+
+func (tr *pagedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	page, _ := strconv.Atoi(req.URL.Query().Get("p"))
+	if page == 0 {
+		page = 1
+	}
+
+	tr.mu.Lock()
+	tr.requested = append(tr.requested, page)
+	tr.inFlight++
+	tr.maxFlight = max(tr.maxFlight, tr.inFlight)
+	tr.mu.Unlock()
+
+	// Later pages answer faster, so out-of-order completion is likely.
+	time.Sleep(time.Duration(40-5*min(page, 6)) * time.Millisecond)
+
+	tr.mu.Lock()
+	tr.inFlight--
+	tr.mu.Unlock()
+
+	body := "<html><body></body></html>"
+	if page <= tr.last {
+		body = fmt.Sprintf(`<html><body><div test-data="ad-search-card"><a href="/oglas/%d">oglas</a></div></body></html>`, page)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func pagedSite(t *testing.T, last int) (Site, *pagedTransport) {
+	t.Helper()
+	tr := &pagedTransport{last: last}
+	httpMode = HTTPModeReplay
+	httpTransport = tr
+	t.Cleanup(func() { configureHTTPMode(HTTPModeLive, "") })
+	return testSiteWithURLs(t, "4zida.rs", "http://stub.test/list", "http://stub.test/list?p=%d"), tr
+}
+
+func TestPageFetcherOrder(t *testing.T) {
+	site, tr := pagedSite(t, 5)
+	fetcher := newPageFetcher(context.Background(), site, FetchPolicy{Workers: 3})
+	defer fetcher.drop()
+
+	var links []string
+	for page := 1; ; page++ {
+		estates, _, err := fetcher.get(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(estates) == 0 {
+			break
+		}
+		links = append(links, estates[0].Link)
+	}
+
+	for i, link := range links {
+		if want := fmt.Sprintf("http://stub.test/oglas/%d", i+1); link != want {
+			t.Errorf("page %d: link %s; want %s", i+1, link, want)
+		}
+	}
+	if len(links) != 5 {
+		t.Errorf("got %d pages; want 5", len(links))
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.maxFlight < 2 || tr.maxFlight > 3 {
+		t.Errorf("%d requests in flight; want 2 or 3", tr.maxFlight)
+	}
+	if len(tr.requested) > 8 {
+		t.Errorf("requested pages %v; want at most 2 past the empty page", tr.requested)
+	}
+}
+
+func TestPageFetcherLimit(t *testing.T) {
+	site, tr := pagedSite(t, 10)
+	fetcher := newPageFetcher(context.Background(), site, FetchPolicy{Workers: 4})
+	defer fetcher.drop()
+	fetcher.limit(2)
+
+	for page := 1; page <= 2; page++ {
+		if _, _, err := fetcher.get(page); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if len(tr.requested) != 2 {
+		t.Errorf("requested pages %v; want only 1 and 2", tr.requested)
+	}
+}
+
+func TestPlannedLastPage(t *testing.T) {
+	plan := crawlPlan{pageSize: 25, totalItems: 596, totalPages: 30}
+	cases := []struct {
+		spec PaginationSpec
+		want int
+	}{
+		{PaginationSpec{Strategy: PaginationUntilEmpty}, 0},
+		{PaginationSpec{Strategy: PaginationUntilEmpty, MaxPages: 50}, 50},
+		{PaginationSpec{Strategy: PaginationPageCount}, 30},
+		{PaginationSpec{Strategy: PaginationPageCount, MaxPages: 10}, 10},
+		{PaginationSpec{Strategy: PaginationTotalCount, MaxPages: 99}, 30},
+	}
+	for _, c := range cases {
+		if got := plannedLastPage(c.spec, plan); got != c.want {
+			t.Errorf("%+v: last page %d; want %d", c.spec, got, c.want)
+		}
+	}
+	if got := plannedLastPage(PaginationSpec{Strategy: PaginationTotalCount}, crawlPlan{pageSize: 25, totalItems: 596}); got != 24 {
+		t.Errorf("total count: last page %d; want 24", got)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import "sort"
+
+// fillRateFields are the card fields whose fill rate is tracked per site. A
+// sudden drop usually means the portal changed its markup.
+var fillRateFields = []string{"price", "square_meter", "quantity_room", "floor", "district", "who_created"}
+
+// minFillRateSample is the smallest page worth judging; the last page of a
+// listing may hold a handful of odd cards.
+const minFillRateSample = 10
+
+func fieldFilled(e RealEstate, field string) bool {
+	switch field {
+	case "price":
+		return e.Price > 0
+	case "square_meter":
+		return e.SquareMeter > 0
+	case "quantity_room":
+		return e.QuantityRoom > 0
+	case "floor":
+		// parseFloor reports -5 for a missing part and -100 for an unreadable one.
+		return (e.Floor != 0 || e.FloorTotal != 0) && e.Floor > dedupUnknownFloorLimit
+	case "district":
+		return e.District != ""
+	case "who_created":
+		return e.WhoCreated != Unknown
+	}
+	return false
+}
+
+// fillStats counts, per tracked field, how many cards had a value.
+type fillStats struct {
+	cards  int
+	filled map[string]int
+}
+
+func newFillStats() *fillStats {
+	return &fillStats{filled: make(map[string]int, len(fillRateFields))}
+}
+
+func (f *fillStats) add(estates []RealEstate) {
+	for _, e := range estates {
+		f.cards++
+		for _, field := range fillRateFields {
+			if fieldFilled(e, field) {
+				f.filled[field]++
+			}
+		}
+	}
+}
+
+func (f *fillStats) merge(other *fillStats) {
+	f.cards += other.cards
+	for field, count := range other.filled {
+		f.filled[field] += count
+	}
+}
+
+func (f *fillStats) rate(field string) float64 {
+	if f.cards == 0 {
+		return 0
+	}
+	return float64(f.filled[field]) / float64(f.cards)
+}
+
+// belowThresholds returns the fields whose fill rate fell under the minimum
+// configured for the site, or nil when there are too few cards to judge.
+func (f *fillStats) belowThresholds(thresholds map[string]float64) []string {
+	if f.cards < minFillRateSample {
+		return nil
+	}
+
+	var fields []string
+	for field, min := range thresholds {
+		if f.rate(field) < min {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// withPrice drops cards without a price; they cannot be stored or compared.
+func withPrice(estates []RealEstate) []RealEstate {
+	priced := estates[:0]
+	for _, e := range estates {
+		if e.Price > 0 {
+			priced = append(priced, e)
+		}
+	}
+	return priced
+}
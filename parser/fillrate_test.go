@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFieldFilled(t *testing.T) {
+	cases := []struct {
+		name   string
+		estate RealEstate
+		field  string
+		want   bool
+	}{
+		{"price", RealEstate{Price: 100000}, "price", true},
+		{"no price", RealEstate{}, "price", false},
+		{"ground floor", RealEstate{Floor: 0, FloorTotal: 4}, "floor", true},
+		{"basement", RealEstate{Floor: -3, FloorTotal: 4}, "floor", true},
+		{"no floor on card", RealEstate{}, "floor", false},
+		{"unreadable floor", RealEstate{Floor: -100, FloorTotal: 4}, "floor", false},
+		{"missing floor part", RealEstate{Floor: -5, FloorTotal: -5}, "floor", false},
+		{"district", RealEstate{District: "Vračar"}, "district", true},
+		{"who created", RealEstate{WhoCreated: Agent}, "who_created", true},
+		{"unknown creator", RealEstate{}, "who_created", false},
+	}
+
+	for _, c := range cases {
+		if got := fieldFilled(c.estate, c.field); got != c.want {
+			t.Errorf("%s: fieldFilled(%s) = %v; want %v", c.name, c.field, got, c.want)
+		}
+	}
+}
+
+func cardsWith(n int, estate RealEstate) []RealEstate {
+	estates := make([]RealEstate, n)
+	for i := range estates {
+		estates[i] = estate
+	}
+	return estates
+}
+
+func TestFillRateThresholds(t *testing.T) {
+	thresholds := map[string]float64{"price": 0.5, "square_meter": 0.5, "quantity_room": 0.3}
+
+	healthy := newFillStats()
+	healthy.add(cardsWith(18, RealEstate{Price: 100000, SquareMeter: 50, QuantityRoom: 2}))
+	healthy.add(cardsWith(2, RealEstate{Price: 100000}))
+	if got := healthy.rate("square_meter"); got != 0.9 {
+		t.Errorf("square_meter rate = %v; want 0.9", got)
+	}
+	if low := healthy.belowThresholds(thresholds); low != nil {
+		t.Errorf("healthy page flagged: %v", low)
+	}
+
+	// The area selector broke: prices still parse, everything else is gone.
+	broken := newFillStats()
+	broken.add(cardsWith(20, RealEstate{Price: 100000}))
+	want := []string{"quantity_room", "square_meter"}
+	if low := broken.belowThresholds(thresholds); !reflect.DeepEqual(low, want) {
+		t.Errorf("broken page flagged %v; want %v", low, want)
+	}
+
+	small := newFillStats()
+	small.add(cardsWith(minFillRateSample-1, RealEstate{}))
+	if low := small.belowThresholds(thresholds); low != nil {
+		t.Errorf("page below the sample size flagged: %v", low)
+	}
+
+	run := newFillStats()
+	run.merge(healthy)
+	run.merge(broken)
+	if got := run.rate("square_meter"); got != 0.45 {
+		t.Errorf("merged square_meter rate = %v; want 0.45", got)
+	}
+}
+
+func TestWithPrice(t *testing.T) {
+	estates := []RealEstate{{Price: 1, Link: "a"}, {Link: "b"}, {Price: 2, Link: "c"}}
+	got := withPrice(estates)
+	if len(got) != 2 || got[0].Link != "a" || got[1].Link != "c" {
+		t.Errorf("withPrice = %+v", got)
+	}
+}
+
+func TestSpecFillRateValidation(t *testing.T) {
+	spec := `{"name": "x", "source": "x", "card_selector": ".c",
+		"listings": {"sale": {"start_url": "https://x", "page_url": "https://x?p=%d"}},
+		"min_fill_rates": {"balcony": 0.5}}`
+	if _, err := parseSiteSpec([]byte(spec)); err == nil {
+		t.Error("expected an error for an untracked field")
+	}
+
+	spec = `{"name": "x", "source": "x", "card_selector": ".c",
+		"listings": {"sale": {"start_url": "https://x", "page_url": "https://x?p=%d"}},
+		"min_fill_rates": {"price": 1.5}}`
+	if _, err := parseSiteSpec([]byte(spec)); err == nil {
+		t.Error("expected an error for a rate above 1")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Run modes recorded in scrape_runs. A full run walks every page of a site; an
+// incremental one stops once it is back among listings already stored. The
+// scrape command saves a page range and never counts as a full sweep.
+const (
+	RunModeFull        = "full"
+	RunModeIncremental = "incremental"
+	RunModeRange       = "range"
+)
+
+// fullSweepForced reports whether PARSER_FULL_SWEEP asks every run to walk
+// all pages, as if no site supported incremental runs.
+func fullSweepForced() bool {
+	return os.Getenv("PARSER_FULL_SWEEP") == "true"
+}
+
+// chooseRunMode picks the mode of a run starting at now. Sites without an
+// incremental spec always run in full; the others run in full until one
+// full run has finished since the latest full sweep slot.
+func chooseRunMode(spec *IncrementalSpec, lastFullSweep, now time.Time) string {
+	if spec == nil || lastFullSweep.IsZero() {
+		return RunModeFull
+	}
+	if next := spec.fullSweep.Next(lastFullSweep); !next.After(now) {
+		return RunModeFull
+	}
+	return RunModeIncremental
+}
+
+// runMode looks up the last full sweep of a site and picks the mode of a run
+// starting at now. A failed lookup falls back to a full run.
+func runMode(ctx context.Context, s *Storage, site Site, now time.Time) string {
+	if site.Spec.Incremental == nil {
+		return RunModeFull
+	}
+	if fullSweepForced() {
+		slog.Info("Full sweep forced", "site", site.Name)
+		return RunModeFull
+	}
+	last, err := s.LastFullSweep(ctx, site.Name)
+	if err != nil {
+		slog.Error("Error loading last full sweep", "site", site.Name, "error", err)
+		return RunModeFull
+	}
+	return chooseRunMode(site.Spec.Incremental, last, now)
+}
+
+// pageTally is a snapshot of the item counters of a run, taken before a page
+// is saved to tell what the page changed.
+type pageTally struct {
+	changed   int
+	unchanged int
+}
+
+func (r *SiteRun) tally() pageTally {
+	return pageTally{
+		changed:   r.ItemsInserted + r.ItemsUpdated + r.ItemsFailed,
+		unchanged: r.ItemsUnchanged,
+	}
+}
+
+// knownPage reports whether the page saved since before held only stored
+// listings with unchanged prices. Quarantined cards neither count for nor
+// against it.
+func (r *SiteRun) knownPage(before pageTally) bool {
+	after := r.tally()
+	return after.changed == before.changed && after.unchanged > before.unchanged
+}
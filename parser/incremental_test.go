@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChooseRunMode(t *testing.T) {
+	// Full sweeps on Sundays at 02:00; 2026-10-17 is a Saturday.
+	sweep, err := ParseCron("0 2 * * 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := &IncrementalSpec{StopAfterPages: 3, fullSweep: sweep}
+	sunday := time.Date(2026, 10, 18, 2, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		spec     *IncrementalSpec
+		lastFull time.Time
+		now      time.Time
+		wantMode string
+	}{
+		{"not incremental", nil, sunday, sunday.Add(time.Hour), RunModeFull},
+		{"never swept", spec, time.Time{}, sunday, RunModeFull},
+		{"swept this week", spec, sunday.Add(5 * time.Minute), sunday.Add(24 * time.Hour), RunModeIncremental},
+		{"sweep slot reached", spec, sunday.Add(-6 * 24 * time.Hour), sunday, RunModeFull},
+		{"sweep slot missed", spec, sunday.Add(-7 * 24 * time.Hour), sunday.Add(3 * 24 * time.Hour), RunModeFull},
+		{"before the slot", spec, sunday.Add(-6 * 24 * time.Hour), sunday.Add(-time.Minute), RunModeIncremental},
+	}
+	for _, c := range cases {
+		if got := chooseRunMode(c.spec, c.lastFull, c.now); got != c.wantMode {
+			t.Errorf("%s: mode = %s; want %s", c.name, got, c.wantMode)
+		}
+	}
+}
+
+func TestKnownPage(t *testing.T) {
+	run := newSiteRun("r", Site{Name: "4zida.rs", Source: "4zida.rs"}, time.Now())
+
+	before := run.tally()
+	run.countItems(string(SaveUnchanged), 20)
+	run.countItems("quarantined", 1)
+	if !run.knownPage(before) {
+		t.Error("page of unchanged listings is not known")
+	}
+
+	before = run.tally()
+	run.countItems(string(SaveUnchanged), 19)
+	run.countItems(string(SaveUpdated), 1)
+	if run.knownPage(before) {
+		t.Error("page with a price change is known")
+	}
+
+	before = run.tally()
+	run.countItems(string(SaveUnchanged), 19)
+	run.countItems(string(SaveFailed), 1)
+	if run.knownPage(before) {
+		t.Error("page with a failed save is known")
+	}
+
+	before = run.tally()
+	run.countItems("quarantined", 2)
+	if run.knownPage(before) {
+		t.Error("page without stored listings is known")
+	}
+}
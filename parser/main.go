@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
@@ -46,13 +47,15 @@ func (l *LogRotator) Write(p []byte) (n int, err error) {
 	return l.file.Write(p)
 }
 
-func init() {
+// setupLogging mirrors logs to console and parser.log. dry-run logs to
+// stderr so stdout carries only its NDJSON output.
+func setupLogging(console io.Writer) {
 	logRotator := &LogRotator{
 		Filename: "parser.log",
 		MaxSize:  maxLogSize,
 	}
 
-	multiWriter := io.MultiWriter(os.Stdout, logRotator)
+	multiWriter := io.MultiWriter(console, logRotator)
 	handler := slog.NewTextHandler(multiWriter, &slog.HandlerOptions{
 		Level: slog.LevelDebug,
 	})
@@ -61,93 +64,449 @@ func init() {
 	slog.Info("parser initialized")
 }
 
-func runParser(s *Storage) {
+// runParser scrapes every site once, concurrently, and deduplicates the
+// result unless ctx was cancelled.
+func runParser(ctx context.Context, s *Storage, sites []Site) {
 	slog.Info("Starting parser run...")
 
-	sites := []struct {
-		name string
-		fn   func(int) ([]RealEstate, int, error)
-		max  int // 0 means until no more elements
-	}{
-		{"4zida.rs", FourZidaList, 99},
-		{"halooglasi.com", HaloOglasiList, 0},
-		{"nekretnine.rs", NekretnineList, 0},
-		{"cityexpert.rs", CityExpertList, 0},
-	}
+	// runID identifies this run in estate_raw_html.
+	runID := time.Now().UTC().Format("20060102T150405Z")
 
-	for _, site := range sites {
-		parserStatus.WithLabelValues(site.name).Set(0)
-		lastRunDuration.WithLabelValues(site.name).Set(0)
+	withDetails := detailsEnabled()
+	if withDetails {
+		slog.Info("Detail page pass enabled")
+	}
+	if rawHTMLEnabled() {
+		slog.Info("Raw HTML archive enabled", "run_id", runID)
 	}
 
 	var wg sync.WaitGroup
 	for _, site := range sites {
 		wg.Add(1)
-		go func(sName string, sFn func(int) ([]RealEstate, int, error), sMaxPage int) {
+		go func(site Site) {
 			defer wg.Done()
-			defer parserStatus.WithLabelValues(sName).Set(0)
-			parserStatus.WithLabelValues(sName).Set(1)
-
-			start := time.Now()
-
-			page := 1
-			itemsSoFar := 0
-			totalItemsLimit := 0
-
-			for {
-				if sMaxPage > 0 && page > sMaxPage {
-					break
-				}
-
-				// Stop if we have reached the total items limit found on the site
-				if totalItemsLimit > 0 && itemsSoFar >= totalItemsLimit {
-					slog.Info("Reached total items limit", "site", sName, "limit", totalItemsLimit, "processed", itemsSoFar)
-					break
-				}
-
-				estates, total, err := sFn(page)
-				if err != nil {
-					slog.Error("Error parsing", "site", sName, "page", page, "error", err)
-					parserErrors.WithLabelValues(sName, "list_fetch").Inc()
-					break
-				}
-
-				// Update total limit if we found it and haven't set it yet (or update it if it changes/refined)
-				if total > 0 {
-					totalItemsLimit = total
-				}
-
-				if len(estates) == 0 {
-					break
-				}
-
-				for _, e := range estates {
-					if err := s.SaveEstate(e); err != nil {
-						slog.Error("Error saving estate", "site", sName, "link", e.Link, "error", err)
-						parserErrors.WithLabelValues(sName, "db_save").Inc()
-					} else {
-						processedItems.WithLabelValues(sName, "processed").Inc()
-					}
-				}
-
-				itemsSoFar += len(estates)
-				slog.Info("Saved page", "site", sName, "page", page, "count", len(estates), "total_found", total)
-				page++
-			}
-
-			duration := time.Since(start).Seconds()
-			runDuration.WithLabelValues(sName).Observe(duration)
-			lastRunDuration.WithLabelValues(sName).Set(duration)
-			lastRunTimestamp.WithLabelValues(sName).SetToCurrentTime()
-			slog.Info("Site parsing completed", "site", sName, "duration", duration)
-		}(site.name, site.fn, site.max)
+			runSite(ctx, s, site, runID, withDetails, nil)
+		}(site)
 	}
 
 	wg.Wait()
+	if ctx.Err() != nil {
+		slog.Warn("Parser run interrupted, skipping deduplication")
+		return
+	}
+	runDeduplication(ctx, s)
 	slog.Info("Parser run completed")
 }
 
+// runSite scrapes one site page by page, resuming its checkpoint if the
+// previous run was interrupted, and delists what a complete run did not see.
+// An incremental run (see runMode) stops once it only finds known listings.
+// Cancelling ctx stops the run after the current page; see stopReason. live,
+// when not nil, receives the progress of the run.
+func runSite(ctx context.Context, s *Storage, site Site, runID string, withDetails bool, live *liveRun) {
+	// Saves and bookkeeping outlive ctx, so the page in progress is stored
+	// completely and the run record tells why it stopped.
+	dbCtx := context.WithoutCancel(ctx)
+	sName := site.Name
+	sMaxPage := site.Spec.Pagination.MaxPages
+
+	lastRunDuration.WithLabelValues(sName).Set(0)
+	siteDegraded.WithLabelValues(sName).Set(0)
+	defer parserStatus.WithLabelValues(sName).Set(0)
+	parserStatus.WithLabelValues(sName).Set(1)
+
+	start := time.Now()
+
+	siteRunID := runID
+	runStart := start
+	page := 1
+	itemsSoFar := 0
+	totalItemsLimit := 0
+
+	// complete is set only when the site itself signalled the end of the
+	// listing (empty page or total reached), so every listing had a chance
+	// to be seen and the rest can be marked as delisted.
+	complete := false
+	saveFailed := false
+	runFill := newFillStats()
+
+	// A checkpoint left behind means the process stopped mid-run. Resume
+	// it, keeping its start so listings saved before the restart are not
+	// taken as delisted.
+	run := newSiteRun(runID, site, start)
+	run.Mode = runMode(dbCtx, s, site, start)
+	cp, err := s.LoadCheckpoint(dbCtx, sName)
+	if err != nil {
+		slog.Error("Error loading checkpoint", "site", sName, "error", err)
+		run.countError("checkpoint")
+	}
+	if cp.resumable(start) {
+		siteRunID = cp.RunID
+		runStart = cp.RunStartedAt
+		page = cp.LastPage + 1
+		itemsSoFar = cp.ItemsSoFar
+		totalItemsLimit = cp.TotalItems
+		saveFailed = cp.SaveFailed
+		slog.Info("Resuming unfinished run", "site", sName, "run_id", siteRunID, "page", page, "items", itemsSoFar)
+
+		run = newSiteRun(siteRunID, site, runStart)
+		prev, err := s.LoadSiteRun(dbCtx, siteRunID, sName)
+		if err != nil {
+			slog.Error("Error loading run record", "site", sName, "run_id", siteRunID, "error", err)
+			run.countError("run_record")
+		} else if prev != nil {
+			run = prev
+		}
+		run.Resumed = true
+		run.Termination = ""
+		run.FinishedAt = nil
+	}
+
+	// knownPages counts the consecutive pages of an incremental run that
+	// held nothing new. A resumed run starts counting again.
+	knownPages := 0
+	plan := crawlPlan{totalItems: totalItemsLimit}
+	runProgress.WithLabelValues(sName).Set(0)
+	termination := ""
+	slog.Info("Starting site run", "site", sName, "run_id", siteRunID, "mode", run.Mode)
+	breaker := breakerFor(sName, fetchPolicy)
+	fetcher := newPageFetcher(ctx, site, fetchPolicy)
+	defer fetcher.drop()
+	fetcher.limit(plannedLastPage(site.Spec.Pagination, plan))
+	live.start(siteRunID, runStart, run.Resumed, page-1, itemsSoFar, totalItemsLimit)
+
+	for {
+		if ctx.Err() != nil {
+			termination = stopReason(ctx)
+			slog.Warn("Run stopped", "site", sName, "page", page, "termination", termination)
+			break
+		}
+
+		if sMaxPage > 0 && page > sMaxPage {
+			slog.Info("Reached max page limit", "site", sName, "max_page", sMaxPage)
+			termination = TerminationMaxPages
+			break
+		}
+
+		// Stop if we have reached the total items limit found on the site
+		if site.Spec.Pagination.Strategy == PaginationTotalCount && totalItemsLimit > 0 && itemsSoFar >= totalItemsLimit {
+			slog.Info("Reached total items limit", "site", sName, "limit", totalItemsLimit, "processed", itemsSoFar)
+			complete = true
+			termination = TerminationCompleted
+			break
+		}
+
+		if err := breaker.Wait(ctx); err != nil {
+			termination = stopReason(ctx)
+			slog.Warn("Run stopped", "site", sName, "page", page, "termination", termination)
+			break
+		}
+
+		estates, info, err := fetcher.get(page)
+		if err != nil {
+			// Pages fetched ahead of a failed one likely failed too; they
+			// are fetched again once the site is retried.
+			fetcher.drop()
+		}
+		if err != nil && ctx.Err() != nil {
+			termination = stopReason(ctx)
+			slog.Warn("Run stopped", "site", sName, "page", page, "termination", termination)
+			break
+		}
+		if err != nil {
+			slog.Error("Error parsing", "site", sName, "page", page, "error", err)
+			run.countError("list_fetch")
+			if _, ok := retryReason(err); !ok {
+				termination = TerminationFetchError
+				break
+			}
+			if breaker.Failure() {
+				termination = TerminationCircuitOpen
+				break
+			}
+			// Skip the page instead of ending the run. Its listings were not
+			// seen, so delisting is as unsafe as after a failed save.
+			slog.Warn("Skipping page", "site", sName, "page", page)
+			saveFailed = true
+			page++
+			continue
+		}
+		breaker.Success()
+		run.Pages++
+
+		// Update total limit if we found it and haven't set it yet (or update it if it changes/refined)
+		if info.TotalItems > 0 {
+			totalItemsLimit = info.TotalItems
+		}
+		plan.update(len(estates), info)
+		fetcher.limit(plannedLastPage(site.Spec.Pagination, plan))
+
+		// The first page that tells the size of the listing is compared
+		// with the previous run. A resumed run already did.
+		if run.ReportedItems == 0 && plan.listings() > 0 {
+			checkListingDrop(dbCtx, s, site, run, plan.listings())
+		}
+
+		if len(estates) == 0 {
+			complete = true
+			termination = TerminationCompleted
+			break
+		}
+
+		pageFill := newFillStats()
+		pageFill.add(estates)
+		runFill.merge(pageFill)
+		for _, field := range fillRateFields {
+			fieldFillRate.WithLabelValues(sName, field).Set(runFill.rate(field))
+		}
+
+		// A markup change breaks every page, so stop instead of filling the
+		// database with half-empty rows. The run stays incomplete, so
+		// nothing gets delisted either.
+		if low := pageFill.belowThresholds(site.Spec.MinFillRates); len(low) > 0 {
+			slog.Error("Fill rates collapsed, site degraded", "site", sName, "page", page, "fields", low)
+			siteDegraded.WithLabelValues(sName).Set(1)
+			run.countError("degraded")
+			run.countItems("skipped", len(estates))
+			termination = TerminationDegraded
+			break
+		}
+		before := run.tally()
+		estates, failed := savePage(ctx, s, site, run, page, estates, withDetails)
+		if failed {
+			saveFailed = true
+		}
+		if run.knownPage(before) {
+			knownPages++
+		} else {
+			knownPages = 0
+		}
+
+		itemsSoFar += len(estates)
+		slog.Info("Saved page", "site", sName, "page", page, "count", len(estates), "total_found", info.TotalItems, "total_pages", plan.pages())
+
+		err = s.SaveCheckpoint(dbCtx, Checkpoint{
+			Site:         sName,
+			RunID:        siteRunID,
+			RunStartedAt: runStart,
+			LastPage:     page,
+			ItemsSoFar:   itemsSoFar,
+			TotalItems:   totalItemsLimit,
+			SaveFailed:   saveFailed,
+		})
+		if err != nil {
+			slog.Error("Error saving checkpoint", "site", sName, "page", page, "error", err)
+			run.countError("checkpoint")
+		}
+		if err := s.SaveSiteRun(dbCtx, run); err != nil {
+			slog.Error("Error saving run record", "site", sName, "page", page, "error", err)
+			run.countError("run_record")
+		}
+		runProgress.WithLabelValues(sName).Set(plan.percent(page))
+		live.update(page, itemsSoFar, totalItemsLimit, plan.pages(), plan.percent(page))
+
+		if site.Spec.Pagination.Strategy == PaginationPageCount && plan.totalPages > 0 && page >= plan.totalPages {
+			slog.Info("Reached last linked page", "site", sName, "page", page)
+			complete = true
+			termination = TerminationCompleted
+			break
+		}
+
+		if run.Mode == RunModeIncremental && knownPages >= site.Spec.Incremental.StopAfterPages {
+			slog.Info("Caught up with stored listings", "site", sName, "page", page, "known_pages", knownPages)
+			termination = TerminationCaughtUp
+			break
+		}
+		page++
+	}
+
+	// A listing that shrank suspiciously may be a broken search; its
+	// listings are not gone.
+	dropped := run.Errors["listing_drop"] > 0
+	if complete && !saveFailed && !dropped && itemsSoFar > 0 {
+		count, err := s.MarkDelisted(dbCtx, site.Source, site.ListingType, runStart)
+		if err != nil {
+			run.countError("delist")
+		} else {
+			delistedItems.WithLabelValues(sName).Add(float64(count))
+		}
+	} else if termination == TerminationCaughtUp {
+		slog.Info("Incremental run, delisting left to the next full sweep", "site", sName)
+	} else {
+		slog.Warn("Run incomplete, skipping delisting", "site", sName, "complete", complete, "save_failed", saveFailed, "listing_drop", dropped)
+	}
+
+	// An interrupted run keeps its checkpoint and resumes on the next start.
+	if termination != TerminationInterrupted {
+		if _, err := s.ClearCheckpoints(dbCtx, sName); err != nil {
+			slog.Error("Error clearing checkpoint", "site", sName, "error", err)
+			run.countError("checkpoint")
+		}
+	}
+
+	run.finish(termination, time.Now())
+	if err := s.SaveSiteRun(dbCtx, run); err != nil {
+		slog.Error("Error saving run record", "site", sName, "error", err)
+		run.countError("run_record")
+	}
+	if run.Mode == RunModeFull && (termination == TerminationCompleted || termination == TerminationMaxPages) {
+		lastFullSweepTimestamp.WithLabelValues(sName).SetToCurrentTime()
+	}
+
+	duration := time.Since(start).Seconds()
+	runDuration.WithLabelValues(sName).Observe(duration)
+	lastRunDuration.WithLabelValues(sName).Set(duration)
+	lastRunTimestamp.WithLabelValues(sName).SetToCurrentTime()
+	slog.Info("Site parsing completed", "site", sName, "duration", duration)
+}
+
+// checkListingDrop records the size of the listing reported by the site in
+// run and flags the run when it is far below the previous run's, which keeps
+// it from delisting.
+func checkListingDrop(ctx context.Context, s *Storage, site Site, run *SiteRun, listings int) {
+	run.ReportedItems = listings
+	reportedListings.WithLabelValues(site.Name).Set(float64(listings))
+
+	previous, err := s.PreviousReportedItems(ctx, site.Name, run.RunID)
+	if err != nil {
+		slog.Error("Error loading previous run size", "site", site.Name, "error", err)
+		run.countError("run_record")
+		return
+	}
+	if listingDropped(previous, listings, site.Spec.Pagination.MaxDrop) {
+		slog.Error("Site reports far fewer listings than the previous run", "site", site.Name, "previous", previous, "now", listings)
+		run.countError("listing_drop")
+	}
+}
+
+// savePage stores the priced cards of a listing page: it visits detail pages
+// when enabled, quarantines cards that fail validation and saves the rest in
+// one transaction. It returns the priced cards and whether any of them could
+// not be stored, which makes the run unsafe for delisting. Once ctx is
+// cancelled the remaining detail pages are skipped but the cards are saved.
+func savePage(ctx context.Context, s *Storage, site Site, run *SiteRun, page int, estates []RealEstate, withDetails bool) ([]RealEstate, bool) {
+	dbCtx := context.WithoutCancel(ctx)
+	sName := site.Name
+	saveFailed := false
+	estates = withPrice(estates)
+
+	if withDetails {
+		for i := range estates {
+			if ctx.Err() != nil {
+				slog.Warn("Skipping remaining detail pages", "site", sName, "page", page)
+				break
+			}
+			attrs, err := parseDetailPage(ctx, sName, estates[i].Link, site.Spec.Details)
+			if err != nil {
+				slog.Error("Error parsing details", "site", sName, "link", estates[i].Link, "error", err)
+				run.countError("detail_fetch")
+				continue
+			}
+			estates[i].Attributes = attrs
+		}
+	}
+
+	var valid []RealEstate
+	for _, e := range estates {
+		failed := validateEstate(e)
+		if len(failed) == 0 {
+			valid = append(valid, e)
+			continue
+		}
+		for _, rule := range failed {
+			validationFailures.WithLabelValues(sName, rule).Inc()
+		}
+		if err := s.QuarantineEstate(dbCtx, e, failed); err != nil {
+			slog.Error("Error quarantining estate", "site", sName, "link", e.Link, "error", err)
+			run.countError("db_save")
+			saveFailed = true
+		} else {
+			slog.Warn("Estate quarantined", "site", sName, "link", e.Link, "rules", failed)
+			run.countItems("quarantined", 1)
+		}
+	}
+
+	outcomes, err := s.SaveEstates(dbCtx, valid)
+	if err != nil {
+		slog.Error("Error saving page", "site", sName, "page", page, "error", err)
+		run.countError("db_save")
+	}
+	for _, outcome := range outcomes {
+		run.countItems(string(outcome), 1)
+		if outcome == SaveFailed {
+			saveFailed = true
+		}
+	}
+
+	for _, e := range estates {
+		if e.RawHTML != "" && e.Link != "" {
+			if err := s.SaveRawHTML(dbCtx, run.RunID, e); err != nil {
+				slog.Error("Error saving raw html", "site", sName, "link", e.Link, "error", err)
+				run.countError("raw_html")
+			}
+		}
+	}
+	return estates, saveFailed
+}
+
 func main() {
+	command, args := "run", os.Args[1:]
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	if command == "help" || command == "-h" || command == "--help" {
+		fmt.Println(usage)
+		return
+	}
+
+	if command == "dry-run" {
+		setupLogging(os.Stderr)
+	} else {
+		setupLogging(os.Stdout)
+	}
+
+	grace, err := shutdownGracePeriod()
+	if err != nil {
+		slog.Error("Failed to configure shutdown", "error", err)
+		os.Exit(1)
+	}
+	ctx := shutdownContext(grace)
+
+	archiveDir := os.Getenv("PARSER_HTTP_ARCHIVE")
+	if archiveDir == "" {
+		archiveDir = "http-archive"
+	}
+	if err := configureHTTPMode(os.Getenv("PARSER_HTTP_MODE"), archiveDir); err != nil {
+		slog.Error("Failed to configure HTTP mode", "error", err)
+		os.Exit(1)
+	}
+	if err := configureFetchPolicy(); err != nil {
+		slog.Error("Failed to configure retries", "error", err)
+		os.Exit(1)
+	}
+
+	specs, err := LoadSiteSpecs(os.Getenv("SITES_DIR"))
+	if err != nil {
+		slog.Error("Failed to load site specs", "error", err)
+		os.Exit(1)
+	}
+	sites := Sites(specs)
+	slog.Info("Site specs loaded", "specs", len(specs), "sites", len(sites))
+
+	if err := configurePoliteness(specs); err != nil {
+		slog.Error("Failed to configure politeness", "error", err)
+		os.Exit(1)
+	}
+
+	// dry-run only parses, so it works without a database.
+	if command == "dry-run" {
+		if err := dryRunCommand(ctx, sites, args, os.Stdout); err != nil {
+			slog.Error("Command failed", "command", command, "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	dbHost := os.Getenv("DB_HOST")
 	dbPort := os.Getenv("DB_PORT")
 	dbUser := os.Getenv("PROJECT_USER")
@@ -169,31 +528,107 @@ func main() {
 		os.Exit(1)
 	}
 
+	// migrate runs before the automatic upgrade, so status and down see the
+	// schema as it is.
+	if command == "migrate" {
+		if err := migrateCommand(storage, args); err != nil {
+			slog.Error("Command failed", "command", command, "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := storage.Migrate(); err != nil {
 		slog.Error("Failed to run migrations", "error", err)
 		os.Exit(1)
 	}
 
+	if path := os.Getenv("EXCHANGE_RATES_FILE"); path != "" {
+		rates, err := LoadExchangeRatesFile(path)
+		if err != nil {
+			slog.Error("Failed to load exchange rates", "error", err)
+			os.Exit(1)
+		}
+		if err := storage.SaveExchangeRates(rates); err != nil {
+			slog.Error("Failed to import exchange rates", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Exchange rates imported", "file", path, "rates", len(rates))
+	}
+	if err := storage.LoadExchangeRates(); err != nil {
+		slog.Error("Failed to load exchange rates", "error", err)
+		os.Exit(1)
+	}
+	if count, err := storage.BackfillEURPrices(); err != nil {
+		slog.Error("Failed to backfill EUR prices", "error", err)
+	} else if count > 0 {
+		slog.Info("EUR prices backfilled", "estates", count)
+	}
+
+	switch command {
+	case "run", "once":
+		// scraped below
+	case "scrape":
+		err = scrapeCommand(ctx, storage, sites, args)
+	case "reparse":
+		err = reparseCommand(storage, specs, args)
+	case "quarantine":
+		err = quarantineCommand(storage, args)
+	case "checkpoints":
+		err = checkpointsCommand(storage, args)
+	default:
+		err = fmt.Errorf("unknown command %q\n%s", command, usage)
+	}
+	if command != "run" && command != "once" {
+		if err != nil {
+			slog.Error("Command failed", "command", command, "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if freshRunForced() {
+		count, err := storage.ClearCheckpoints(ctx, "")
+		if err != nil {
+			slog.Error("Failed to clear checkpoints", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Fresh run forced, checkpoints cleared", "checkpoints", count)
+	}
+
+	if command == "once" {
+		runParser(ctx, storage, sites)
+		return
+	}
+
+	jitter, err := scheduleJitter()
+	if err != nil {
+		slog.Error("Failed to configure scheduler", "error", err)
+		os.Exit(1)
+	}
+
 	// Start Prometheus metrics server
 	metricsPort := os.Getenv("METRICS_PORT")
 	if metricsPort == "" {
 		metricsPort = "2112" // fallback
 	}
 
+	scheduler := NewScheduler(ctx, storage, sites, jitter)
+
 	go func() {
 		http.Handle("/metrics", promhttp.Handler())
+		http.Handle("/runs", runsHandler(storage.ListSiteRuns))
+		if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+			http.Handle("/admin/", adminHandler(scheduler, token))
+		} else {
+			slog.Warn("ADMIN_TOKEN is not set, admin API disabled")
+		}
 		slog.Info("Starting metrics server", "port", metricsPort)
 		if err := http.ListenAndServe(":"+metricsPort, nil); err != nil {
 			slog.Error("Metrics server failed", "error", err)
 		}
 	}()
 
-	runParser(storage)
-
-	ticker := time.NewTicker(48 * time.Hour)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		runParser(storage)
-	}
+	scheduler.Run()
+	slog.Info("Parser stopped")
 }
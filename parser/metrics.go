@@ -11,11 +11,41 @@ var (
 		Help: "Total number of real estate items processed",
 	}, []string{"site", "status"})
 
+	fieldFillRate = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "parser_field_fill_rate",
+		Help: "Share of cards with a value for the field in the current or last run",
+	}, []string{"site", "field"})
+
+	validationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "parser_validation_failures_total",
+		Help: "Total number of estates that violated a validation rule",
+	}, []string{"site", "rule"})
+
+	siteDegraded = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "parser_site_degraded",
+		Help: "1 when the last run stopped because field fill rates collapsed",
+	}, []string{"site"})
+
 	parserErrors = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "parser_errors_total",
 		Help: "Total number of errors during parsing",
 	}, []string{"site", "phase"})
 
+	fetchRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "parser_fetch_retries_total",
+		Help: "Total number of listing page fetches retried, by status code or network",
+	}, []string{"site", "reason"})
+
+	breakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "parser_circuit_breaker_state",
+		Help: "Circuit breaker state per site: 0 closed, 1 half-open, 2 open",
+	}, []string{"site"})
+
+	breakerTrips = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "parser_circuit_breaker_trips_total",
+		Help: "Total number of times a site's circuit breaker opened",
+	}, []string{"site"})
+
 	lastRunTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "parser_last_run_timestamp_seconds",
 		Help: "Unix timestamp of the last successful run per site",
@@ -32,6 +62,51 @@ var (
 		Help: "Duration of the last successful run in seconds",
 	}, []string{"site"})
 
+	delistedItems = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "parser_items_delisted_total",
+		Help: "Total number of listings marked as delisted after a complete run",
+	}, []string{"site"})
+
+	dedupDuplicates = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "parser_dedup_duplicate_listings",
+		Help: "Number of active listings that duplicate another listing of the same cluster",
+	})
+
+	politenessViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "parser_politeness_violations_total",
+		Help: "Requests that broke the politeness policy of their domain, by rule (robots, robots_unavailable, time_window)",
+	}, []string{"domain", "rule"})
+
+	rateLimitWait = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "parser_rate_limit_wait_seconds_total",
+		Help: "Time requests waited for the rate limiter of their domain",
+	}, []string{"domain"})
+
+	runProgress = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "parser_run_progress_percent",
+		Help: "Share of the expected pages of the running site done, 0 while unknown",
+	}, []string{"site"})
+
+	reportedListings = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "parser_reported_listings",
+		Help: "Listings the site reported on the first page of its last run",
+	}, []string{"site"})
+
+	lastFullSweepTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "parser_last_full_sweep_timestamp_seconds",
+		Help: "Unix timestamp of the last full run per site that walked every page",
+	}, []string{"site"})
+
+	nextRunTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "parser_next_run_timestamp_seconds",
+		Help: "Unix timestamp of the next scheduled run per site, jitter included",
+	}, []string{"site"})
+
+	scheduleSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "parser_schedule_skipped_total",
+		Help: "Total number of triggers skipped because the site was still running",
+	}, []string{"site"})
+
 	parserStatus = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "parser_active_status",
 		Help: "Current status of the parser: 1 for running, 0 for idle",
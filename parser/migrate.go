@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var embeddedMigrations embed.FS
+
+// migrationLockKey serializes migrations of concurrently starting processes
+// with a transaction-level advisory lock.
+const migrationLockKey = 4127318
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one schema version with the SQL that applies and reverts it.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is a migration together with when it was applied, if ever.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// loadMigrations reads NNNN_name.up.sql / NNNN_name.down.sql pairs from the
+// root of fsys, ordered by version. Every version needs both files and
+// versions must count up from 1 without gaps.
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		m := migrationFileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, m[2])
+		}
+		if m[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must count up from 1, found %d at position %d", m.Version, i+1)
+		}
+	}
+	return migrations, nil
+}
+
+func schemaMigrations() ([]Migration, error) {
+	sub, err := fs.Sub(embeddedMigrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return loadMigrations(sub)
+}
+
+// Migrate applies every pending migration.
+func (s *Storage) Migrate() error {
+	applied, err := s.MigrateUp(context.Background())
+	if err != nil {
+		return err
+	}
+	slog.Info("Database migration completed successfully", "applied", applied)
+	return nil
+}
+
+func (s *Storage) ensureMigrationsTable(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	);`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+func (s *Storage) appliedMigrations(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// ListMigrations lists every known migration and when it was applied.
+func (s *Storage) ListMigrations(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := schemaMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		status[i].Migration = m
+		if at, ok := applied[m.Version]; ok {
+			status[i].AppliedAt = &at
+		}
+	}
+	return status, nil
+}
+
+// MigrateUp applies pending migrations in order, each in its own transaction
+// together with its schema_migrations row, and returns how many it applied.
+func (s *Storage) MigrateUp(ctx context.Context) (int, error) {
+	migrations, err := schemaMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if err := s.ensureMigrationsTable(ctx); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		ok, err := s.runMigration(ctx, m, true)
+		if err != nil {
+			return count, err
+		}
+		if ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// MigrateDown reverts the latest applied migrations, up to steps of them.
+func (s *Storage) MigrateDown(ctx context.Context, steps int) (int, error) {
+	migrations, err := schemaMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if err := s.ensureMigrationsTable(ctx); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		ok, err := s.runMigration(ctx, migrations[i], false)
+		if err != nil {
+			return count, err
+		}
+		if ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// runMigration applies (up) or reverts (down) m unless that already
+// happened, reporting whether it changed anything.
+func (s *Storage) runMigration(ctx context.Context, m Migration, up bool) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLockKey); err != nil {
+		return false, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+
+	var applied bool
+	err = tx.QueryRowContext(ctx, "SELECT TRUE FROM schema_migrations WHERE version = $1", m.Version).Scan(&applied)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("failed to check migration %d: %w", m.Version, err)
+	}
+	if applied == up {
+		return false, nil
+	}
+
+	if up {
+		if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+			return false, fmt.Errorf("failed to apply migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)",
+			m.Version, m.Name, time.Now())
+	} else {
+		if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+			return false, fmt.Errorf("failed to revert migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", m.Version)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migration %d: %w", m.Version, err)
+	}
+	slog.Info("Migration completed", "version", m.Version, "name", m.Name, "up", up)
+	return true, nil
+}
+
+// migrateCommand implements "migrate status", "migrate up" and
+// "migrate down [-steps N]".
+func migrateCommand(s *Storage, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate status | migrate up | migrate down [-steps N]")
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "status":
+		status, err := s.ListMigrations(ctx)
+		if err != nil {
+			return err
+		}
+		printMigrationStatus(os.Stdout, status)
+		return nil
+	case "up":
+		count, err := s.MigrateUp(ctx)
+		fmt.Printf("applied %d migration(s)\n", count)
+		return err
+	case "down":
+		fs := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := fs.Int("steps", 1, "number of migrations to revert")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *steps < 1 {
+			return errors.New("-steps must be at least 1")
+		}
+		count, err := s.MigrateDown(ctx, *steps)
+		fmt.Printf("reverted %d migration(s)\n", count)
+		return err
+	}
+	return fmt.Errorf("unknown migrate command %q", args[0])
+}
+
+func printMigrationStatus(w io.Writer, status []MigrationStatus) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+	for _, m := range status {
+		applied := "pending"
+		if m.AppliedAt != nil {
+			applied = m.AppliedAt.Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\n", m.Version, m.Name, applied)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := schemaMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	if migrations[0].Name != "estates" {
+		t.Errorf("first migration = %s; want estates", migrations[0].Name)
+	}
+
+	// Deployments created before versioning already have these tables, so
+	// applying a migration to them must not fail.
+	for _, m := range migrations {
+		for _, stmt := range strings.Split(m.Up, ";") {
+			stmt = strings.TrimSpace(stmt)
+			if strings.HasPrefix(stmt, "CREATE") || strings.HasPrefix(stmt, "ALTER TABLE") {
+				if !strings.Contains(stmt, "IF NOT EXISTS") {
+					t.Errorf("%04d_%s: statement is not idempotent: %s", m.Version, m.Name, stmt)
+				}
+			}
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	file := func(sql string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(sql)} }
+
+	valid := fstest.MapFS{
+		"0002_b.up.sql":   file("CREATE TABLE b ()"),
+		"0002_b.down.sql": file("DROP TABLE b"),
+		"0001_a.up.sql":   file("CREATE TABLE a ()"),
+		"0001_a.down.sql": file("DROP TABLE a"),
+	}
+	migrations, err := loadMigrations(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Name != "a" || migrations[1].Down != "DROP TABLE b" {
+		t.Errorf("migrations = %+v", migrations)
+	}
+
+	cases := map[string]fstest.MapFS{
+		"missing down": {"0001_a.up.sql": file("x")},
+		"gap": {
+			"0001_a.up.sql": file("x"), "0001_a.down.sql": file("x"),
+			"0003_c.up.sql": file("x"), "0003_c.down.sql": file("x"),
+		},
+		"two names":  {"0001_a.up.sql": file("x"), "0001_b.down.sql": file("x")},
+		"stray file": {"0001_a.up.sql": file("x"), "0001_a.down.sql": file("x"), "README.md": file("x")},
+	}
+	for name, fsys := range cases {
+		if _, err := loadMigrations(fsys); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestPrintMigrationStatus(t *testing.T) {
+	at := time.Date(2026, 10, 1, 4, 0, 0, 0, time.UTC)
+	status := []MigrationStatus{
+		{Migration: Migration{Version: 1, Name: "estates"}, AppliedAt: &at},
+		{Migration: Migration{Version: 2, Name: "price_history"}},
+	}
+
+	var buf bytes.Buffer
+	printMigrationStatus(&buf, status)
+	want := "VERSION  NAME           APPLIED\n" +
+		"0001     estates        2026-10-01 04:00:00\n" +
+		"0002     price_history  pending\n"
+	if buf.String() != want {
+		t.Errorf("status =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS estates (
+	id SERIAL PRIMARY KEY,
+	price INTEGER,
+	currency TEXT,
+	price_per_sqm INTEGER,
+	square_meter INTEGER,
+	city TEXT,
+	district TEXT,
+	municipality TEXT,
+	street TEXT,
+	full_location TEXT,
+	who_created INTEGER,
+	quantity_room REAL,
+	floor REAL,
+	floor_total REAL,
+	link TEXT UNIQUE,
+	parsing_date TIMESTAMP,
+	source TEXT
+);
+
+ALTER TABLE estates ADD COLUMN IF NOT EXISTS attributes JSONB;
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS estate_price_history (
+	id SERIAL PRIMARY KEY,
+	link TEXT NOT NULL,
+	price INTEGER,
+	currency TEXT,
+	price_per_sqm INTEGER,
+	observed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS estate_price_history_link_idx ON estate_price_history (link, observed_at);
+
+-- Seed the history with the current price of listings saved before the table existed.
+INSERT INTO estate_price_history (link, price, currency, price_per_sqm, observed_at)
+SELECT e.link, e.price, e.currency, e.price_per_sqm, COALESCE(e.parsing_date, NOW())
+FROM estates e
+WHERE NOT EXISTS (SELECT 1 FROM estate_price_history h WHERE h.link = e.link);
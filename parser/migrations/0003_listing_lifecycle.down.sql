@@ -0,0 +1,4 @@
+ALTER TABLE estates DROP COLUMN IF EXISTS delisted_at;
+ALTER TABLE estates DROP COLUMN IF EXISTS active;
+ALTER TABLE estates DROP COLUMN IF EXISTS last_seen_at;
+ALTER TABLE estates DROP COLUMN IF EXISTS first_seen_at;
@@ -0,0 +1,7 @@
+ALTER TABLE estates ADD COLUMN IF NOT EXISTS first_seen_at TIMESTAMP;
+ALTER TABLE estates ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP;
+ALTER TABLE estates ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE estates ADD COLUMN IF NOT EXISTS delisted_at TIMESTAMP;
+
+UPDATE estates SET first_seen_at = parsing_date WHERE first_seen_at IS NULL;
+UPDATE estates SET last_seen_at = parsing_date WHERE last_seen_at IS NULL;
@@ -0,0 +1 @@
+ALTER TABLE estates DROP COLUMN IF EXISTS cluster_id;
@@ -0,0 +1,3 @@
+ALTER TABLE estates ADD COLUMN IF NOT EXISTS cluster_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS estates_cluster_id_idx ON estates (cluster_id);
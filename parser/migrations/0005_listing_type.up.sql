@@ -0,0 +1 @@
+ALTER TABLE estates ADD COLUMN IF NOT EXISTS listing_type TEXT NOT NULL DEFAULT 'sale';
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS estate_raw_html;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS estate_raw_html (
+	link TEXT NOT NULL,
+	run_id TEXT NOT NULL,
+	source TEXT NOT NULL,
+	listing_type TEXT NOT NULL,
+	page_url TEXT NOT NULL,
+	html BYTEA NOT NULL,
+	fetched_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (link, run_id)
+);
+
+CREATE INDEX IF NOT EXISTS estate_raw_html_fetched_idx ON estate_raw_html (link, fetched_at DESC);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS estates_quarantine;
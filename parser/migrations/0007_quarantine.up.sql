@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS estates_quarantine (
+	id SERIAL PRIMARY KEY,
+	link TEXT UNIQUE,
+	source TEXT NOT NULL,
+	listing_type TEXT NOT NULL,
+	rules TEXT[] NOT NULL,
+	estate JSONB NOT NULL,
+	quarantined_at TIMESTAMP NOT NULL
+);
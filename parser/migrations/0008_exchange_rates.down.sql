@@ -0,0 +1,3 @@
+ALTER TABLE estates DROP COLUMN IF EXISTS price_per_sqm_eur;
+ALTER TABLE estates DROP COLUMN IF EXISTS price_eur;
+DROP TABLE IF EXISTS exchange_rates;
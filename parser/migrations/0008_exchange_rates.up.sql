@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS exchange_rates (
+	date DATE NOT NULL,
+	currency TEXT NOT NULL,
+	rate DOUBLE PRECISION NOT NULL,
+	PRIMARY KEY (currency, date)
+);
+
+ALTER TABLE estates ADD COLUMN IF NOT EXISTS price_eur INTEGER;
+ALTER TABLE estates ADD COLUMN IF NOT EXISTS price_per_sqm_eur INTEGER;
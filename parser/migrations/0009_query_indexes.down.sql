@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS estates_cluster_representative_idx;
+DROP INDEX IF EXISTS estates_listing_type_parsing_date_idx;
+DROP INDEX IF EXISTS estates_source_idx;
+DROP INDEX IF EXISTS estates_district_idx;
+DROP INDEX IF EXISTS estates_parsing_date_idx;
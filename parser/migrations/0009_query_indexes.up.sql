@@ -0,0 +1,8 @@
+-- Filters of the ML engine (GetRealEstateWithoutDuplicate) and of delisting.
+CREATE INDEX IF NOT EXISTS estates_parsing_date_idx ON estates (parsing_date);
+CREATE INDEX IF NOT EXISTS estates_district_idx ON estates (district);
+CREATE INDEX IF NOT EXISTS estates_source_idx ON estates (source, listing_type, last_seen_at);
+CREATE INDEX IF NOT EXISTS estates_listing_type_parsing_date_idx ON estates (listing_type, parsing_date);
+
+-- One representative per duplicate cluster: DISTINCT ON (COALESCE(cluster_id, id)).
+CREATE INDEX IF NOT EXISTS estates_cluster_representative_idx ON estates ((COALESCE(cluster_id, id)), parsing_date DESC);
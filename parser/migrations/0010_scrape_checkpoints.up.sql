@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS scrape_checkpoints (
+	site TEXT PRIMARY KEY,
+	run_id TEXT NOT NULL,
+	run_started_at TIMESTAMP NOT NULL,
+	last_page INTEGER NOT NULL,
+	items_so_far INTEGER NOT NULL,
+	total_items INTEGER NOT NULL,
+	save_failed BOOLEAN NOT NULL DEFAULT FALSE,
+	updated_at TIMESTAMP NOT NULL
+);
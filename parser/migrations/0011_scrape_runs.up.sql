@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS scrape_runs (
+	run_id TEXT NOT NULL,
+	site TEXT NOT NULL,
+	source TEXT NOT NULL,
+	listing_type TEXT NOT NULL,
+	started_at TIMESTAMP NOT NULL,
+	finished_at TIMESTAMP,
+	resumed BOOLEAN NOT NULL DEFAULT FALSE,
+	pages INTEGER NOT NULL DEFAULT 0,
+	items_inserted INTEGER NOT NULL DEFAULT 0,
+	items_updated INTEGER NOT NULL DEFAULT 0,
+	items_unchanged INTEGER NOT NULL DEFAULT 0,
+	items_failed INTEGER NOT NULL DEFAULT 0,
+	items_quarantined INTEGER NOT NULL DEFAULT 0,
+	items_skipped INTEGER NOT NULL DEFAULT 0,
+	errors JSONB NOT NULL DEFAULT '{}',
+	termination TEXT,
+	PRIMARY KEY (run_id, site)
+);
+
+CREATE INDEX IF NOT EXISTS scrape_runs_started_at_idx ON scrape_runs (started_at DESC);
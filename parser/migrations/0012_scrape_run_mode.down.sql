@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS scrape_runs_site_mode_idx;
+ALTER TABLE scrape_runs DROP COLUMN IF EXISTS mode;
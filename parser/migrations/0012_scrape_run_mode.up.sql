@@ -0,0 +1,3 @@
+ALTER TABLE scrape_runs ADD COLUMN IF NOT EXISTS mode TEXT NOT NULL DEFAULT 'full';
+
+CREATE INDEX IF NOT EXISTS scrape_runs_site_mode_idx ON scrape_runs (site, mode, started_at DESC);
@@ -0,0 +1 @@
+ALTER TABLE scrape_runs DROP COLUMN IF EXISTS reported_items;
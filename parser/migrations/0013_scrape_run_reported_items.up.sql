@@ -0,0 +1 @@
+ALTER TABLE scrape_runs ADD COLUMN IF NOT EXISTS reported_items INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// PageInfo is what a listing page tells about the whole listing: the
+// listings the site announces and the last page its pagination links to.
+// Zero means the page did not say.
+type PageInfo struct {
+	TotalItems int
+	TotalPages int
+}
+
+// defaultPagesSelector looks at every link of a page; only those matching
+// the listing's page_url are taken as pagination.
+const defaultPagesSelector = "a[href]"
+
+// defaultMaxDrop flags a listing that shrank by more than half since the
+// previous run.
+const defaultMaxDrop = 0.5
+
+// pageNumberPattern matches the URLs of a page_url template, capturing the
+// page number.
+func pageNumberPattern(pageURL string) *regexp.Regexp {
+	before, after, _ := strings.Cut(pageURL, "%d")
+	return regexp.MustCompile("^" + regexp.QuoteMeta(before) + `(\d+)` + regexp.QuoteMeta(after) + "$")
+}
+
+// lastLinkedPage returns the highest page number among the links matched by
+// selector that point to pageURL pages, or 0 when there are none. Portals
+// that only link a window of pages around the current one still always link
+// the next page, so the result only falls to the current page on the last
+// one.
+func lastLinkedPage(e *colly.HTMLElement, selector, pageURL string) int {
+	pattern := pageNumberPattern(pageURL)
+	last := 0
+	e.ForEach(selector, func(_ int, el *colly.HTMLElement) {
+		m := pattern.FindStringSubmatch(el.Request.AbsoluteURL(el.Attr("href")))
+		if m == nil {
+			return
+		}
+		if n, err := strconv.Atoi(m[1]); err == nil && n > last {
+			last = n
+		}
+	})
+	return last
+}
+
+// parseTotalCount reads the listings announced by a results counter, either
+// "shown of total" such as "571-596 od 596 rezultata" or a plain count such
+// as "12.345 oglasa".
+func parseTotalCount(text string) int {
+	if strings.Contains(text, " od ") {
+		return parseCityExpertTotalCount(text)
+	}
+	return int(parseNumeric(text))
+}
+
+// crawlPlan is the size of a listing as far as a run knows it, refined by
+// every page.
+type crawlPlan struct {
+	pageSize   int // cards on the first page seen
+	totalItems int
+	totalPages int
+}
+
+func (p *crawlPlan) update(cards int, info PageInfo) {
+	if p.pageSize == 0 && cards > 0 {
+		p.pageSize = cards
+	}
+	if info.TotalItems > 0 {
+		p.totalItems = info.TotalItems
+	}
+	if info.TotalPages > 0 {
+		p.totalPages = info.TotalPages
+	}
+}
+
+// pages is the number of pages the run expects: the last page linked, or the
+// announced total divided by the page size. It is 0 when unknown.
+func (p crawlPlan) pages() int {
+	if p.totalPages > 0 {
+		return p.totalPages
+	}
+	if p.totalItems > 0 && p.pageSize > 0 {
+		return (p.totalItems + p.pageSize - 1) / p.pageSize
+	}
+	return 0
+}
+
+// listings is the size of the listing as the site reports it: the announced
+// total, or the expected pages times the page size.
+func (p crawlPlan) listings() int {
+	if p.totalItems > 0 {
+		return p.totalItems
+	}
+	return p.pages() * p.pageSize
+}
+
+// percent is the share of the expected pages done once page is saved, or 0
+// when the number of pages is unknown.
+func (p crawlPlan) percent(page int) float64 {
+	pages := p.pages()
+	if pages == 0 {
+		return 0
+	}
+	return min(100, 100*float64(page)/float64(pages))
+}
+
+// listingDropped reports whether a site reports far fewer listings than in
+// the previous run, more than maxDrop of them gone. That is rather a broken
+// search or pagination than a market change.
+func listingDropped(previous, current int, maxDrop float64) bool {
+	return previous > 0 && current > 0 && float64(current) < float64(previous)*(1-maxDrop)
+}
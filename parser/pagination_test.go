@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+func TestParseTotalCount(t *testing.T) {
+	cases := map[string]int{
+		"571-596 od 596 rezultata": 596,
+		"12.345 oglasa":            12345,
+		"Pronađeno 87 oglasa":      87,
+		"nema rezultata":           0,
+	}
+	for text, want := range cases {
+		if got := parseTotalCount(text); got != want {
+			t.Errorf("parseTotalCount(%q) = %d; want %d", text, got, want)
+		}
+	}
+}
+
+func TestPageNumberPattern(t *testing.T) {
+	pattern := pageNumberPattern("https://cityexpert.rs/prodaja-nekretnina/beograd?ptId=1&currentPage=%d")
+	if m := pattern.FindStringSubmatch("https://cityexpert.rs/prodaja-nekretnina/beograd?ptId=1&currentPage=24"); m == nil || m[1] != "24" {
+		t.Errorf("page link not matched: %v", m)
+	}
+	for _, url := range []string{
+		"https://cityexpert.rs/izdavanje-nekretnina/beograd?ptId=1&currentPage=24",
+		"https://cityexpert.rs/prodaja-nekretnina/beograd?ptId=1&currentPage=24&sort=price",
+		"https://cityexpert.rs/prodaja-nekretnina/beogradXptId=1&currentPage=24",
+	} {
+		if pattern.MatchString(url) {
+			t.Errorf("%s matched", url)
+		}
+	}
+}
+
+func TestCrawlPlan(t *testing.T) {
+	var plan crawlPlan
+	if plan.pages() != 0 || plan.percent(1) != 0 {
+		t.Errorf("empty plan: %d pages, %v%%", plan.pages(), plan.percent(1))
+	}
+
+	// A total and the first page's size give the pages.
+	plan.update(25, PageInfo{TotalItems: 596})
+	plan.update(25, PageInfo{})
+	if plan.pages() != 24 || plan.listings() != 596 || plan.percent(6) != 25 {
+		t.Errorf("total plan: %d pages, %d listings, %v%%", plan.pages(), plan.listings(), plan.percent(6))
+	}
+
+	// Linked pages win over the total, and the short last page does not
+	// change the page size.
+	plan.update(10, PageInfo{TotalPages: 30})
+	if plan.pages() != 30 || plan.percent(60) != 100 {
+		t.Errorf("linked plan: %d pages, %v%%", plan.pages(), plan.percent(60))
+	}
+
+	linked := crawlPlan{}
+	linked.update(20, PageInfo{TotalPages: 42})
+	if linked.listings() != 840 {
+		t.Errorf("listings from pages = %d; want 840", linked.listings())
+	}
+}
+
+func TestListingDropped(t *testing.T) {
+	cases := []struct {
+		previous, current int
+		want              bool
+	}{
+		{0, 100, false},
+		{1000, 0, false},
+		{1000, 600, false},
+		{1000, 500, false},
+		{1000, 499, true},
+		{1000, 40, true},
+	}
+	for _, c := range cases {
+		if got := listingDropped(c.previous, c.current, 0.5); got != c.want {
+			t.Errorf("listingDropped(%d, %d) = %v; want %v", c.previous, c.current, got, c.want)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -21,6 +23,13 @@ const (
 	Investor
 )
 
+// Listing types. Rent listings store the monthly rent in Price and the
+// monthly rent per square meter in PricePerSquareMeter.
+const (
+	ListingSale = "sale"
+	ListingRent = "rent"
+)
+
 type RealEstate struct {
 	Price               int32
 	Currency            string
@@ -38,28 +47,36 @@ type RealEstate struct {
 	Link                string
 	ParsingDate         time.Time
 	Source              string
+	ListingType         string
+	Attributes          EstateAttributes
+	PageURL             string // listing page the card was found on
+	RawHTML             string // card HTML, kept only when STORE_RAW_HTML is on
 }
 
 func (w WhoCreated) String() string {
 	return [...]string{"Unknown", "Agent", "User", "Investor"}[w]
 }
 
-func setupParser() *colly.Collector {
-	const userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+// setupParser returns a collector whose requests are aborted once ctx is
+// cancelled.
+func setupParser(ctx context.Context) *colly.Collector {
 	const accept = "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"
 	const acceptLanguage = "en-US,en;q=0.9,sr;q=0.8,rs;q=0.7"
 	const referer = "https://www.google.com/"
-	const delay = 2 * time.Second
-	const randomDelay = 3 * time.Second
-	const parallelism = 1
-
-	parser := colly.NewCollector()
-	parser.Limit(&colly.LimitRule{
-		DomainGlob:  "*",
-		Delay:       delay,
-		RandomDelay: randomDelay,
-		Parallelism: parallelism,
-	})
+
+	parser := colly.NewCollector(colly.StdlibContext(ctx))
+
+	// Collectors live for one page, so politeness is kept per domain by a
+	// transport shared in effect by all of them. Replayed responses come
+	// from disk, so there is no site to be polite to.
+	transport := httpTransport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if httpMode != HTTPModeReplay {
+		transport = politeTransport(transport, fetchPolicy)
+	}
+	parser.WithTransport(transport)
 
 	parser.OnRequest(func(r *colly.Request) {
 		r.Headers.Set("User-Agent", userAgent)
@@ -76,222 +93,63 @@ func setupParser() *colly.Collector {
 	return parser
 }
 
-type EstateParser func(e *colly.HTMLElement) RealEstate
-
-func parseWebSiteData(domen string, page int, firstPage string, secondPage string, goLangQuery string, callback EstateParser, paginationCallback func(*colly.HTMLElement) int) ([]RealEstate, int, error) {
-	parser := setupParser()
+func parseWebSiteData(ctx context.Context, site Site, page int) ([]RealEstate, PageInfo, error) {
+	parser := setupParser(ctx)
 	var parsingError error
 	var estates []RealEstate
-	var totalItems int
+	var info PageInfo
+	domen := site.Source
 
 	parser.OnResponse(func(r *colly.Response) {
 		if r.StatusCode != 200 {
 			slog.Error("request failed for", "domen", domen, "status code", r.StatusCode, "url", r.Request.URL)
-			parsingError = fmt.Errorf("request failed for %s: %d %s", domen, r.StatusCode, r.Request.URL)
+			parsingError = newHTTPStatusError(domen, r)
 		}
 	})
 
-	parser.OnHTML(goLangQuery, func(e *colly.HTMLElement) {
-		estate := callback(e)
-		if estate.Price > 0 {
-			estates = append(estates, estate)
+	// Error statuses skip OnResponse; keep them so callers can retry.
+	parser.OnError(func(r *colly.Response, err error) {
+		if r != nil && r.StatusCode != 0 {
+			parsingError = newHTTPStatusError(domen, r)
 		}
 	})
 
-	// Add pagination callback if provided
-	if paginationCallback != nil {
-		// CityExpert specific selector for now, but could be parameterized
-		if domen == "cityexpert.rs" {
-			parser.OnHTML(".cx-pagination span", func(e *colly.HTMLElement) {
-				count := paginationCallback(e)
-				if count > 0 {
-					totalItems = count
-				}
-			})
-		}
-	}
+	parser.OnHTML("html", func(e *colly.HTMLElement) {
+		estates, info = site.parsePage(e)
+	})
 
 	if page <= 0 {
 		slog.Error("page must be greater than 0", "page", page)
-		return nil, 0, errors.New("page must be greater than 0")
+		return nil, PageInfo{}, errors.New("page must be greater than 0")
 	}
 
-	visitError := error(nil)
-	if page == 1 {
-		visitError = parser.Visit(firstPage)
-	} else {
-		visitError = parser.Visit(fmt.Sprintf(secondPage, page))
+	pageURL := site.URLs.StartURL
+	if page > 1 {
+		pageURL = fmt.Sprintf(site.URLs.PageURL, page)
 	}
 
-	if visitError != nil {
-		slog.Error("visit failed for", "domen", domen, "url", firstPage, "error", visitError)
-		return nil, 0, visitError
+	if err := parser.Visit(pageURL); err != nil {
+		slog.Error("visit failed for", "domen", domen, "url", pageURL, "error", err)
+		if parsingError != nil {
+			return nil, PageInfo{}, parsingError
+		}
+		return nil, PageInfo{}, err
 	}
 
 	if parsingError != nil {
 		slog.Error("parsing failed for", "domen", domen, "error", parsingError)
-		return nil, 0, parsingError
+		return nil, PageInfo{}, parsingError
 	}
 
 	count := len(estates)
-	slog.Info("parsing successfully finished", "domain", domen, "count", count, "totalItems", totalItems)
+	slog.Info("parsing successfully finished", "domain", domen, "count", count, "totalItems", info.TotalItems, "totalPages", info.TotalPages)
 
 	if count > 0 {
 		slog.Info("first element", "data", estates[0])
 		slog.Info("last element", "data", estates[count-1])
 	}
 
-	return estates, totalItems, nil
-}
-
-func FourZidaList(page int) ([]RealEstate, int, error) {
-	estates, total, err := parseWebSiteData("4zida.rs", page, "https://www.4zida.rs/prodaja-stanova/beograd", "https://www.4zida.rs/prodaja-stanova/beograd?strana=%d", "[test-data='ad-search-card']", parse4ZidaCard, nil)
-	return estates, total, err
-}
-
-func parse4ZidaCard(e *colly.HTMLElement) RealEstate {
-	priceStr := e.ChildText("div.w-3\\/8 p:nth-child(1)")
-	location := e.ChildText("p.line-clamp-2")
-
-	estate := RealEstate{
-		Source:       "4zida.rs",
-		Street:       e.ChildText("p.truncate"),
-		FullLocation: location,
-		Link:         e.Request.AbsoluteURL(e.ChildAttr("a", "href")),
-		Price:        parseNumeric(priceStr),
-		Currency:     parseCurrency(priceStr),
-		ParsingDate:  time.Now(),
-	}
-
-	estate.PricePerSquareMeter = parseNumeric(e.ChildText("div.w-3\\/8 p:nth-child(2)"))
-
-	parseLocationParts4Zida(location, &estate)
-	parseDetails(e.ChildText("a.px-3"), &estate)
-
-	metaStr := e.ChildText("div:nth-child(3) div:nth-child(1) span")
-	if strings.Contains(metaStr, "Agencija") {
-		estate.WhoCreated = Agent
-	} else if strings.Contains(metaStr, "Investitor") {
-		estate.WhoCreated = Investor
-	} else if strings.Contains(metaStr, "Vlasnik") {
-		estate.WhoCreated = User
-	}
-
-	return estate
-}
-
-func HaloOglasiList(page int) ([]RealEstate, int, error) {
-	estates, total, err := parseWebSiteData("halooglasi.com", page, "https://www.halooglasi.com/nekretnine/prodaja-stanova/beograd", "https://www.halooglasi.com/nekretnine/prodaja-stanova/beograd?page=%d", ".product-item", parseHaloOglasiCard, nil)
-	return estates, total, err
-}
-
-func parseHaloOglasiCard(e *colly.HTMLElement) RealEstate {
-	priceStr := e.ChildText(".central-feature span[data-value]")
-	if priceStr == "" {
-		priceStr = e.ChildText(".central-feature i")
-	}
-
-	estate := RealEstate{
-		Source:      "halooglasi.com",
-		Link:        e.Request.AbsoluteURL(e.ChildAttr(".product-title a", "href")),
-		Price:       parseNumeric(priceStr),
-		Currency:    parseCurrency(priceStr),
-		ParsingDate: time.Now(),
-	}
-
-	estate.PricePerSquareMeter = parseNumeric(e.ChildText(".price-by-surface span"))
-
-	var locParts []string
-	e.ForEach(".subtitle-places li", func(_ int, el *colly.HTMLElement) {
-		locParts = append(locParts, strings.TrimSpace(el.Text))
-	})
-	if len(locParts) > 0 {
-		estate.FullLocation = strings.Join(locParts, ", ")
-		if len(locParts) >= 2 {
-			estate.City = locParts[0]
-			estate.Municipality = locParts[1]
-		}
-		if len(locParts) >= 3 {
-			estate.City = locParts[0]
-			estate.Municipality = locParts[1]
-			estate.District = locParts[2]
-		}
-		if len(locParts) >= 4 {
-			estate.City = locParts[0]
-			estate.Municipality = locParts[1]
-			estate.District = locParts[2]
-			estate.Street = locParts[3]
-		}
-	}
-
-	e.ForEach(".product-features li", func(_ int, el *colly.HTMLElement) {
-		val := el.ChildText(".value-wrapper")
-		legend := el.ChildText(".legend")
-
-		switch legend {
-		case "Kvadratura":
-			estate.SquareMeter = parseNumeric(val)
-		case "Broj soba":
-			roomsStr := strings.TrimSpace(strings.ReplaceAll(val, "Broj soba", ""))
-			if r, err := strconv.ParseFloat(roomsStr, 32); err == nil {
-				estate.QuantityRoom = float32(r)
-			}
-		case "Spratnost":
-			floorVal := strings.ReplaceAll(val, "Spratnost", "")
-			estate.Floor, estate.FloorTotal = parseFloor(floorVal)
-		}
-	})
-
-	if strings.Contains(strings.ToLower(e.ChildText(".basic-info")), "agencija") {
-		estate.WhoCreated = Agent
-	} else if strings.Contains(strings.ToLower(e.ChildText(".basic-info")), "vlasnik") {
-		estate.WhoCreated = User
-	}
-
-	return estate
-}
-
-func NekretnineList(page int) ([]RealEstate, int, error) {
-	estates, total, err := parseWebSiteData("nekretnine.rs", page, "https://www.nekretnine.rs/stambeni-objekti/stanovi/izdavanje-prodaja/prodaja/grad/beograd/lista/", "https://www.nekretnine.rs/stambeni-objekti/stanovi/izdavanje-prodaja/prodaja/grad/beograd/lista/stranica/%d/", ".row.offer", parseNekretnineCard, nil)
-	return estates, total, err
-}
-
-func parseNekretnineCard(e *colly.HTMLElement) RealEstate {
-	priceStr := e.ChildText(".offer-price:not(.offer-price--invert) span")
-	location := strings.TrimSpace(e.ChildText(".offer-location"))
-
-	estate := RealEstate{
-		Source:       "nekretnine.rs",
-		FullLocation: location,
-		Link:         e.Request.AbsoluteURL(e.ChildAttr("h2.offer-title a", "href")),
-		Price:        parseNumeric(priceStr),
-		Currency:     parseCurrency(priceStr),
-		ParsingDate:  time.Now(),
-	}
-
-	estate.PricePerSquareMeter = parseNumeric(e.ChildText(".offer-price:not(.offer-price--invert) small"))
-	estate.SquareMeter = parseNumeric(e.ChildText(".offer-price--invert span"))
-
-	parseLocationPartsNekretnine(location, &estate)
-
-	metaStr := strings.ToUpper(e.ChildText(".owner-box"))
-	if strings.Contains(metaStr, "AGENCIJA") {
-		estate.WhoCreated = Agent
-	} else if strings.Contains(metaStr, "VLASNIK") {
-		estate.WhoCreated = User
-	} else if strings.Contains(metaStr, "INVESTITOR") {
-		estate.WhoCreated = Investor
-	}
-
-	dateParts := strings.Split(strings.TrimSpace(e.ChildText(".offer-meta-info")), " | ")
-	for _, part := range dateParts {
-		part = strings.TrimSpace(part)
-		if strings.Contains(part, "soban") || strings.Contains(part, "Garsonjera") {
-			estate.QuantityRoom = parseSerbianRooms(part)
-		}
-	}
-
-	return estate
+	return estates, info, nil
 }
 
 func parseSerbianRooms(s string) float32 {
@@ -329,17 +187,9 @@ func parseSerbianRooms(s string) float32 {
 	return 0
 }
 
-func CityExpertList(page int) ([]RealEstate, int, error) {
-	return parseWebSiteData("cityexpert.rs", page, "https://cityexpert.rs/prodaja-nekretnina/beograd?ptId=1", "https://cityexpert.rs/prodaja-nekretnina/beograd?ptId=1&currentPage=%d", ".prop-card", parseCityExpertCard, parseCityExpertPagination)
-}
-
-func parseCityExpertPagination(e *colly.HTMLElement) int {
-	text := strings.TrimSpace(e.Text)
-	return parseCityExpertTotalCount(text)
-}
-
+// parseCityExpertTotalCount reads the total from a "shown of total" counter
+// such as "571-596 od 596 rezultata".
 func parseCityExpertTotalCount(text string) int {
-	// Example: "571-596 od 596 rezultata"
 	parts := strings.Split(text, " od ")
 	if len(parts) == 2 {
 		totalStr := strings.TrimSpace(strings.Split(parts[1], " ")[0])
@@ -348,37 +198,6 @@ func parseCityExpertTotalCount(text string) int {
 	return 0
 }
 
-func parseCityExpertCard(e *colly.HTMLElement) RealEstate {
-	priceStr := e.ChildText(".property-card__price-value span")
-	location := strings.TrimSpace(e.ChildText(".property-card__place:not(.property-card__place--break)"))
-
-	estate := RealEstate{
-		Source:       "cityexpert.rs",
-		FullLocation: location,
-		Link:         "https://cityexpert.rs" + e.ChildAttr("a", "href"),
-		Price:        parseNumeric(priceStr),
-		Currency:     parseCurrency(priceStr),
-		ParsingDate:  time.Now(),
-	}
-
-	e.ForEach(".property-card__feature", func(_ int, el *colly.HTMLElement) {
-		text := strings.TrimSpace(el.Text)
-		if strings.Contains(text, "m²") {
-			estate.SquareMeter = parseNumeric(text)
-			estate.PricePerSquareMeter = estate.Price / estate.SquareMeter
-		} else if strings.Contains(text, "Spavaćih soba") {
-			numStr := strings.TrimSpace(strings.ReplaceAll(text, "Spavaćih soba", ""))
-			if rooms, err := strconv.ParseFloat(numStr, 32); err == nil {
-				estate.QuantityRoom = float32(rooms)
-			}
-		}
-	})
-
-	parseLocationPartsCE(location, &estate)
-
-	return estate
-}
-
 func parseLocationPartsNekretnine(location string, estate *RealEstate) {
 	parts := strings.Split(location, ",")
 	if len(parts) >= 2 {
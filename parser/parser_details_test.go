@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+)
+
+// loadFixture builds the root HTML element of a saved page, as colly would
+// hand it to an OnHTML("html") callback for pageURL.
+func loadFixture(t *testing.T, name string, pageURL string) *colly.HTMLElement {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to open fixture %s: %v", name, err)
+	}
+	defer f.Close()
+
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatalf("failed to parse fixture %s: %v", name, err)
+	}
+
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &colly.Response{Request: &colly.Request{URL: u}}
+	root := doc.Find("html")
+	return colly.NewHTMLElementFromSelectionNode(resp, root, root.Nodes[0], 0)
+}
+
+func TestExtractDetails(t *testing.T) {
+	cases := []struct {
+		fixture  string
+		link     string
+		site     string
+		expected EstateAttributes
+	}{
+		{
+			fixture: "4zida_detail.html",
+			link:    "https://www.4zida.rs/prodaja-stanova/vracar/1",
+			site:    "4zida.rs",
+			expected: EstateAttributes{
+				Heating:    "Centralno grejanje",
+				YearBuilt:  1978,
+				Condition:  "Renovirano",
+				Elevator:   boolPtr(true),
+				Parking:    boolPtr(false),
+				Terrace:    boolPtr(true),
+				Registered: boolPtr(true),
+			},
+		},
+		{
+			fixture: "halooglasi_detail.html",
+			link:    "https://www.halooglasi.com/nekretnine/prodaja-stanova/zvezdara/1",
+			site:    "halooglasi.com",
+			expected: EstateAttributes{
+				Heating:    "TA peć",
+				YearBuilt:  2019,
+				Condition:  "Izvorno stanje",
+				Elevator:   boolPtr(true),
+				Terrace:    boolPtr(true),
+				Registered: boolPtr(false),
+			},
+		},
+		{
+			fixture: "nekretnine_detail.html",
+			link:    "https://www.nekretnine.rs/stambeni-objekti/stanovi/1/",
+			site:    "nekretnine.rs",
+			expected: EstateAttributes{
+				Heating:    "Centralno",
+				YearBuilt:  1985,
+				Condition:  "Dobro",
+				Elevator:   boolPtr(true),
+				Parking:    boolPtr(true),
+				Registered: boolPtr(true),
+			},
+		},
+		{
+			fixture: "cityexpert_detail.html",
+			link:    "https://cityexpert.rs/prodaja/stan/1",
+			site:    "cityexpert.rs",
+			expected: EstateAttributes{
+				Heating:   "Podno",
+				YearBuilt: 2021,
+				Elevator:  boolPtr(false),
+				Parking:   boolPtr(true),
+				Terrace:   boolPtr(true),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.fixture, func(t *testing.T) {
+			got := extractDetails(loadFixture(t, c.fixture, c.link), testSite(t, c.site).Spec.Details)
+
+			if got.Heating != c.expected.Heating {
+				t.Errorf("Heating = %q; want %q", got.Heating, c.expected.Heating)
+			}
+			if got.YearBuilt != c.expected.YearBuilt {
+				t.Errorf("YearBuilt = %d; want %d", got.YearBuilt, c.expected.YearBuilt)
+			}
+			if got.Condition != c.expected.Condition {
+				t.Errorf("Condition = %q; want %q", got.Condition, c.expected.Condition)
+			}
+			checkFlag(t, "Elevator", got.Elevator, c.expected.Elevator)
+			checkFlag(t, "Parking", got.Parking, c.expected.Parking)
+			checkFlag(t, "Terrace", got.Terrace, c.expected.Terrace)
+			checkFlag(t, "Registered", got.Registered, c.expected.Registered)
+		})
+	}
+}
+
+func checkFlag(t *testing.T, name string, got, want *bool) {
+	t.Helper()
+	switch {
+	case got == nil && want == nil:
+	case got == nil || want == nil:
+		t.Errorf("%s = %v; want %v", name, got, want)
+	case *got != *want:
+		t.Errorf("%s = %v; want %v", name, *got, *want)
+	}
+}
+
+func TestParseYesNo(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected bool
+	}{
+		{"lift da", true},
+		{"lift", true},
+		{"lift ne", false},
+		{"nije uknjižen", false},
+		{"parking nema", false},
+		{"terasa 2", true},
+	}
+
+	for _, c := range cases {
+		if got := parseYesNo(c.input); got != c.expected {
+			t.Errorf("parseYesNo(%q) = %v; want %v", c.input, got, c.expected)
+		}
+	}
+}
@@ -32,17 +32,17 @@ func TestCityExpertFullLoop(t *testing.T) {
 		}
 
 		fmt.Printf("Requesting page %d...\n", page)
-		estates, total, err := CityExpertList(page)
+		estates, info, err := testSite(t, "cityexpert.rs").List(page)
 		if err != nil {
 			t.Fatalf("Error parsing page %d: %v", page, err)
 		}
 
 		// Simulate finding total items (logic from main.go)
-		if total > 0 {
+		if info.TotalItems > 0 {
 			if totalItemsLimit == 0 {
-				fmt.Printf("Found total items count: %d\n", total)
+				fmt.Printf("Found total items count: %d\n", info.TotalItems)
 			}
-			totalItemsLimit = total
+			totalItemsLimit = info.TotalItems
 		}
 
 		if len(estates) == 0 {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -46,7 +47,7 @@ func TestFourZidaConnection(t *testing.T) {
 }
 
 func TestFourZidaListFirstPage(t *testing.T) {
-	list, _, err := FourZidaList(1)
+	list, _, err := testSite(t, "4zida.rs").List(1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -56,7 +57,7 @@ func TestFourZidaListFirstPage(t *testing.T) {
 }
 
 func TestFourZidaListSecondPage(t *testing.T) {
-	list, _, err := FourZidaList(2)
+	list, _, err := testSite(t, "4zida.rs").List(2)
 	if err != nil {
 		t.Error(err)
 	}
@@ -66,7 +67,7 @@ func TestFourZidaListSecondPage(t *testing.T) {
 }
 
 func TestFourZidaFloor(t *testing.T) {
-	list, _, err := parseWebSiteData("4zida.rs", 1, "https://www.4zida.rs/prodaja-stanova/beograd?sprat_od=-4&sprat_do=-1", "https://www.4zida.rs/prodaja-stanova/beograd?sprat_od=-4&sprat_do=-1&strana=%d", "[test-data='ad-search-card']", parse4ZidaCard, nil)
+	list, _, err := parseWebSiteData(context.Background(), testSiteWithURLs(t, "4zida.rs", "https://www.4zida.rs/prodaja-stanova/beograd?sprat_od=-4&sprat_do=-1", "https://www.4zida.rs/prodaja-stanova/beograd?sprat_od=-4&sprat_do=-1&strana=%d"), 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -90,19 +91,19 @@ func TestFourZidaGenral(t *testing.T) {
 	var list []RealEstate
 
 	for i := 1; i < 10; i++ {
-		listCommon, _, err := FourZidaList(i)
+		listCommon, _, err := testSite(t, "4zida.rs").List(i)
 		if err != nil {
 			t.Error(err)
 		}
 		list = append(list, listCommon...)
 
-		listFloor, _, err := parseWebSiteData("4zida.rs", 1, "https://www.4zida.rs/prodaja-stanova/beograd?sprat_od=-4&sprat_do=-2", "https://www.4zida.rs/prodaja-stanova/beograd?sprat_od=-4&sprat_do=-1&strana=%d", "[test-data='ad-search-card']", parse4ZidaCard, nil)
+		listFloor, _, err := parseWebSiteData(context.Background(), testSiteWithURLs(t, "4zida.rs", "https://www.4zida.rs/prodaja-stanova/beograd?sprat_od=-4&sprat_do=-2", "https://www.4zida.rs/prodaja-stanova/beograd?sprat_od=-4&sprat_do=-1&strana=%d"), 1)
 		if err != nil {
 			t.Error(err)
 		}
 		list = append(list, listFloor...)
 
-		listWhoCreated, _, err := parseWebSiteData("4zida.rs", 1, "https://www.4zida.rs/prodaja-stanova/beograd/investitor?oglasivac=vlasnik", "https://www.4zida.rs/prodaja-stanova/beograd/investitor?oglasivac=vlasnik&strana=%d", "[test-data='ad-search-card']", parse4ZidaCard, nil)
+		listWhoCreated, _, err := parseWebSiteData(context.Background(), testSiteWithURLs(t, "4zida.rs", "https://www.4zida.rs/prodaja-stanova/beograd/investitor?oglasivac=vlasnik", "https://www.4zida.rs/prodaja-stanova/beograd/investitor?oglasivac=vlasnik&strana=%d"), 1)
 		if err != nil {
 			t.Error(err)
 		}
@@ -166,7 +167,7 @@ func TestHaloOglasiConnection(t *testing.T) {
 }
 
 func TestHaloOglasiListFirstPage(t *testing.T) {
-	list, _, err := HaloOglasiList(1)
+	list, _, err := testSite(t, "halooglasi.com").List(1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -176,7 +177,7 @@ func TestHaloOglasiListFirstPage(t *testing.T) {
 }
 
 func TestHaloOglasiListSecondPage(t *testing.T) {
-	list, _, err := HaloOglasiList(2)
+	list, _, err := testSite(t, "halooglasi.com").List(2)
 	if err != nil {
 		t.Error(err)
 	}
@@ -190,7 +191,7 @@ func TestCityExpertConnection(t *testing.T) {
 }
 
 func TestCityExpertListFirstPage(t *testing.T) {
-	list, _, err := CityExpertList(1)
+	list, _, err := testSite(t, "cityexpert.rs").List(1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -200,7 +201,7 @@ func TestCityExpertListFirstPage(t *testing.T) {
 }
 
 func TestCityExpertListSecondPage(t *testing.T) {
-	list, _, err := CityExpertList(2)
+	list, _, err := testSite(t, "cityexpert.rs").List(2)
 	if err != nil {
 		t.Error(err)
 	}
@@ -214,7 +215,7 @@ func TestNekretninersConnection(t *testing.T) {
 }
 
 func TestNekretninersListFirstPage(t *testing.T) {
-	list, _, err := NekretnineList(1)
+	list, _, err := testSite(t, "nekretnine.rs").List(1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -224,7 +225,7 @@ func TestNekretninersListFirstPage(t *testing.T) {
 }
 
 func TestNekretninersListSecondPage(t *testing.T) {
-	list, _, err := NekretnineList(2)
+	list, _, err := testSite(t, "nekretnine.rs").List(2)
 	if err != nil {
 		t.Error(err)
 	}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultUserAgent identifies the parser to the portals, so their robots.txt
+// can address it and their operators can tell it apart from browsers.
+const defaultUserAgent = "BelgradeEstateML-parser/1.0"
+
+// PolitenessSpec is how gently a portal is scraped, on top of the rate
+// limit every domain gets (see FetchPolicy). Delay is the least time between
+// two requests, MaxRequestsPerHour caps requests over any hour and Windows
+// are the local times of day requests are allowed in, such as "01:00-06:00"
+// or "22:00-02:00"; no windows means any time.
+type PolitenessSpec struct {
+	Delay              string   `json:"delay,omitempty"`
+	MaxRequestsPerHour int      `json:"max_requests_per_hour,omitempty"`
+	Windows            []string `json:"windows,omitempty"`
+
+	delay   time.Duration
+	windows []timeWindow
+}
+
+// timeWindow is a span of the day in minutes since midnight. A window whose
+// end is before its start wraps past midnight.
+type timeWindow struct {
+	from, to int
+}
+
+func (spec *PolitenessSpec) parse() error {
+	if spec.Delay != "" {
+		d, err := time.ParseDuration(spec.Delay)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid delay %q", spec.Delay)
+		}
+		spec.delay = d
+	}
+	if spec.MaxRequestsPerHour < 0 {
+		return errors.New("max_requests_per_hour must not be negative")
+	}
+	spec.windows = nil
+	for _, raw := range spec.Windows {
+		w, err := parseTimeWindow(raw)
+		if err != nil {
+			return err
+		}
+		spec.windows = append(spec.windows, w)
+	}
+	return nil
+}
+
+func parseTimeWindow(raw string) (timeWindow, error) {
+	from, to, ok := strings.Cut(raw, "-")
+	if !ok {
+		return timeWindow{}, fmt.Errorf("invalid window %q: want HH:MM-HH:MM", raw)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return timeWindow{}, fmt.Errorf("invalid window %q: %w", raw, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return timeWindow{}, fmt.Errorf("invalid window %q: %w", raw, err)
+	}
+	if start == end {
+		return timeWindow{}, fmt.Errorf("invalid window %q: empty", raw)
+	}
+	return timeWindow{from: start, to: end}, nil
+}
+
+// parseClock reads "HH:MM" as minutes since midnight; "24:00" ends a day.
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+	hours, err1 := strconv.Atoi(h)
+	minutes, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hours < 0 || minutes < 0 || minutes > 59 ||
+		hours > 24 || (hours == 24 && minutes > 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return hours*60 + minutes, nil
+}
+
+func (w timeWindow) contains(minute int) bool {
+	if w.from < w.to {
+		return minute >= w.from && minute < w.to
+	}
+	return minute >= w.from || minute < w.to
+}
+
+// allowedAt reports whether a request may be sent at t. A nil spec allows
+// any time.
+func (spec *PolitenessSpec) allowedAt(t time.Time) bool {
+	if spec == nil || len(spec.windows) == 0 {
+		return true
+	}
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range spec.windows {
+		if w.contains(minute) {
+			return true
+		}
+	}
+	return false
+}
+
+// PolitenessError is a request refused because it would break the politeness
+// policy of its domain. It is not retried.
+type PolitenessError struct {
+	Domain string
+	URL    string
+	Rule   string // robots, robots_unavailable or time_window
+	Err    error  // why robots.txt is unavailable
+}
+
+func (e *PolitenessError) Error() string {
+	switch e.Rule {
+	case "robots":
+		return fmt.Sprintf("%s is disallowed by robots.txt of %s", e.URL, e.Domain)
+	case "robots_unavailable":
+		return fmt.Sprintf("%s is not fetched while robots.txt of %s is unavailable: %v", e.URL, e.Domain, e.Err)
+	case "time_window":
+		return fmt.Sprintf("%s is outside the allowed time windows of %s", e.URL, e.Domain)
+	}
+	return fmt.Sprintf("%s breaks the %s rule of %s", e.URL, e.Rule, e.Domain)
+}
+
+var (
+	// userAgent is sent with every request.
+	userAgent = defaultUserAgent
+	// politenessByHost holds the politeness of every host a spec lists.
+	politenessByHost = make(map[string]*PolitenessSpec)
+	// robots is nil when robots.txt is not checked.
+	robots     *robotsCache
+	robotsMode = RobotsEnforce
+)
+
+// configurePoliteness registers the politeness of every portal under the
+// hosts of its listings and reads PARSER_USER_AGENT, ROBOTS_TXT and
+// ROBOTS_CACHE_TTL. It is called after configureHTTPMode, since robots.txt
+// is recorded like any page and not checked at all in replay mode.
+func configurePoliteness(specs []SiteSpec) error {
+	if ua := os.Getenv("PARSER_USER_AGENT"); ua != "" {
+		userAgent = ua
+	}
+
+	hosts := make(map[string]*PolitenessSpec)
+	for i := range specs {
+		spec := &specs[i]
+		if spec.Politeness == nil {
+			continue
+		}
+		for _, urls := range spec.Listings {
+			u, err := url.Parse(urls.StartURL)
+			if err != nil {
+				return fmt.Errorf("site %s: invalid start_url: %w", spec.Name, err)
+			}
+			hosts[u.Hostname()] = spec.Politeness
+		}
+	}
+	politenessByHost = hosts
+
+	ttl := defaultRobotsCacheTTL
+	if raw := os.Getenv("ROBOTS_CACHE_TTL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid ROBOTS_CACHE_TTL %q", raw)
+		}
+		ttl = d
+	}
+
+	mode := os.Getenv("ROBOTS_TXT")
+	switch mode {
+	case "", RobotsEnforce:
+		mode = RobotsEnforce
+	case RobotsReport, RobotsIgnore:
+	default:
+		return fmt.Errorf("invalid ROBOTS_TXT %q: want enforce, report or ignore", mode)
+	}
+	robotsMode = mode
+
+	robots = nil
+	if mode != RobotsIgnore && httpMode != HTTPModeReplay {
+		transport := httpTransport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		robots = newRobotsCache(transport, ttl)
+	}
+	slog.Info("Politeness configured", "user_agent", userAgent, "robots_txt", mode, "portals", len(hosts))
+	return nil
+}
+
+func politenessFor(host string) *PolitenessSpec {
+	return politenessByHost[host]
+}
+
+// politeTransport refuses requests outside the time windows of their domain,
+// disallowed by its robots.txt or sent while that robots.txt cannot be
+// fetched, and then waits for the domain's rate limiter. Refused requests
+// are counted in parser_politeness_violations_total so a portal tightening
+// its rules does not go unnoticed.
+func politeTransport(next http.RoundTripper, p FetchPolicy) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		domain := req.URL.Hostname()
+		limiter := limiterFor(domain, p)
+
+		if !politenessFor(domain).allowedAt(time.Now()) {
+			politenessViolations.WithLabelValues(domain, "time_window").Inc()
+			return nil, &PolitenessError{Domain: domain, URL: req.URL.String(), Rule: "time_window"}
+		}
+
+		if robots != nil {
+			allowed, crawlDelay, err := robots.check(req.Context(), req.URL, userAgent)
+			limiter.slowDown(crawlDelay)
+			switch {
+			case err != nil && req.Context().Err() != nil:
+				return nil, err
+			case err != nil:
+				politenessViolations.WithLabelValues(domain, "robots_unavailable").Inc()
+				if robotsMode == RobotsEnforce {
+					return nil, &PolitenessError{Domain: domain, URL: req.URL.String(), Rule: "robots_unavailable", Err: err}
+				}
+				slog.Warn("Fetching a page while robots.txt is unavailable", "url", req.URL.String(), "error", err)
+			case !allowed:
+				politenessViolations.WithLabelValues(domain, "robots").Inc()
+				if robotsMode == RobotsEnforce {
+					return nil, &PolitenessError{Domain: domain, URL: req.URL.String(), Rule: "robots"}
+				}
+				slog.Warn("Fetching a page disallowed by robots.txt", "url", req.URL.String())
+			}
+		}
+
+		if err := limiter.Wait(req.Context(), domain); err != nil {
+			return nil, err
+		}
+		return next.RoundTrip(req)
+	})
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPolitenessSpecParse(t *testing.T) {
+	spec := PolitenessSpec{Delay: "2s", MaxRequestsPerHour: 600, Windows: []string{"01:00-06:00", "22:30-24:00"}}
+	if err := spec.parse(); err != nil {
+		t.Fatal(err)
+	}
+	if spec.delay != 2*time.Second {
+		t.Errorf("delay %v; want 2s", spec.delay)
+	}
+	want := []timeWindow{{60, 360}, {1350, 1440}}
+	if len(spec.windows) != len(want) || spec.windows[0] != want[0] || spec.windows[1] != want[1] {
+		t.Errorf("windows %v; want %v", spec.windows, want)
+	}
+
+	for _, bad := range []PolitenessSpec{
+		{Delay: "soon"},
+		{Delay: "-1s"},
+		{MaxRequestsPerHour: -1},
+		{Windows: []string{"01:00"}},
+		{Windows: []string{"1:00-25:00"}},
+		{Windows: []string{"01:60-02:00"}},
+		{Windows: []string{"03:00-03:00"}},
+	} {
+		if err := bad.parse(); err == nil {
+			t.Errorf("%+v: expected an error", bad)
+		}
+	}
+}
+
+func TestPolitenessAllowedAt(t *testing.T) {
+	spec := &PolitenessSpec{Windows: []string{"22:00-02:00", "12:00-13:00"}}
+	if err := spec.parse(); err != nil {
+		t.Fatal(err)
+	}
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 10, 17, hour, minute, 0, 0, time.Local)
+	}
+	for _, tc := range []struct {
+		t    time.Time
+		want bool
+	}{
+		{at(23, 0), true},
+		{at(0, 30), true},
+		{at(1, 59), true},
+		{at(2, 0), false},
+		{at(12, 30), true},
+		{at(13, 0), false},
+		{at(21, 59), false},
+	} {
+		if got := spec.allowedAt(tc.t); got != tc.want {
+			t.Errorf("allowedAt(%s) = %v; want %v", tc.t.Format("15:04"), got, tc.want)
+		}
+	}
+
+	var none *PolitenessSpec
+	if !none.allowedAt(at(4, 0)) {
+		t.Error("no politeness should allow any time")
+	}
+}
+
+// usePoliteness sets the politeness globals for one test.
+func usePoliteness(t *testing.T, byHost map[string]*PolitenessSpec, cache *robotsCache, mode string) {
+	t.Helper()
+	oldByHost, oldRobots, oldMode := politenessByHost, robots, robotsMode
+	politenessByHost, robots, robotsMode = byHost, cache, mode
+	t.Cleanup(func() {
+		politenessByHost, robots, robotsMode = oldByHost, oldRobots, oldMode
+	})
+}
+
+func TestPoliteTransportRobots(t *testing.T) {
+	robotsTxt := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("User-agent: *\nDisallow: /private\n")),
+			Request:    req,
+		}, nil
+	})
+	sent := 0
+	next := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent++
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+	p := FetchPolicy{Rate: 100, Burst: 100}
+
+	for _, tc := range []struct {
+		mode     string
+		wantSent int
+	}{
+		{RobotsEnforce, 1},
+		{RobotsReport, 2},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			usePoliteness(t, nil, newRobotsCache(robotsTxt, time.Hour), tc.mode)
+			sent = 0
+			domain := tc.mode + ".robots.test"
+			before := testutil.ToFloat64(politenessViolations.WithLabelValues(domain, "robots"))
+			transport := politeTransport(next, p)
+
+			req, _ := http.NewRequest(http.MethodGet, "http://"+domain+"/listings", nil)
+			if _, err := transport.RoundTrip(req); err != nil {
+				t.Fatal(err)
+			}
+			req, _ = http.NewRequest(http.MethodGet, "http://"+domain+"/private/1", nil)
+			_, err := transport.RoundTrip(req)
+			var refused *PolitenessError
+			if refused := errors.As(err, &refused); refused != (tc.mode == RobotsEnforce) {
+				t.Errorf("refused %v, error %v", refused, err)
+			}
+
+			if sent != tc.wantSent {
+				t.Errorf("sent %d requests; want %d", sent, tc.wantSent)
+			}
+			if got := testutil.ToFloat64(politenessViolations.WithLabelValues(domain, "robots")) - before; got != 1 {
+				t.Errorf("counted %v robots violations; want 1", got)
+			}
+		})
+	}
+}
+
+func TestPoliteTransportRobotsUnavailable(t *testing.T) {
+	robotsTxt := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+	sent := 0
+	next := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent++
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+	p := FetchPolicy{Rate: 100, Burst: 100}
+
+	for _, tc := range []struct {
+		mode     string
+		wantSent int
+	}{
+		{RobotsEnforce, 0},
+		{RobotsReport, 1},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			usePoliteness(t, nil, newRobotsCache(robotsTxt, time.Hour), tc.mode)
+			sent = 0
+			domain := tc.mode + ".unavailable.test"
+			before := testutil.ToFloat64(politenessViolations.WithLabelValues(domain, "robots_unavailable"))
+
+			req, _ := http.NewRequest(http.MethodGet, "http://"+domain+"/listings", nil)
+			_, err := politeTransport(next, p).RoundTrip(req)
+			if tc.mode == RobotsEnforce {
+				var refused *PolitenessError
+				if !errors.As(err, &refused) || refused.Rule != "robots_unavailable" {
+					t.Fatalf("got error %v; want a robots_unavailable refusal", err)
+				}
+				if _, retry := retryReason(err); retry {
+					t.Error("a refused request should not be retried")
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if sent != tc.wantSent {
+				t.Errorf("sent %d requests; want %d", sent, tc.wantSent)
+			}
+			if got := testutil.ToFloat64(politenessViolations.WithLabelValues(domain, "robots_unavailable")) - before; got != 1 {
+				t.Errorf("counted %v robots_unavailable violations; want 1", got)
+			}
+		})
+	}
+}
+
+func TestPoliteTransportTimeWindow(t *testing.T) {
+	// A one-minute window twelve hours from now is never open during the test.
+	now := time.Now()
+	from := now.Add(12 * time.Hour)
+	spec := &PolitenessSpec{Windows: []string{from.Format("15:04") + "-" + from.Add(time.Minute).Format("15:04")}}
+	if err := spec.parse(); err != nil {
+		t.Fatal(err)
+	}
+	usePoliteness(t, map[string]*PolitenessSpec{"window.test": spec}, nil, RobotsEnforce)
+
+	transport := politeTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		t.Error("a request outside the time windows was sent")
+		return nil, errors.New("unexpected request")
+	}), FetchPolicy{Rate: 100, Burst: 100})
+	before := testutil.ToFloat64(politenessViolations.WithLabelValues("window.test", "time_window"))
+
+	req, _ := http.NewRequest(http.MethodGet, "http://window.test/1", nil)
+	_, err := transport.RoundTrip(req)
+	var refused *PolitenessError
+	if !errors.As(err, &refused) || refused.Rule != "time_window" {
+		t.Fatalf("got error %v; want a time_window refusal", err)
+	}
+	if _, retry := retryReason(err); retry {
+		t.Error("a refused request should not be retried")
+	}
+	if got := testutil.ToFloat64(politenessViolations.WithLabelValues("window.test", "time_window")) - before; got != 1 {
+		t.Errorf("counted %v time_window violations; want 1", got)
+	}
+}
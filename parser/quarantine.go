@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// QuarantinedEstate is an estate held back by validation.
+type QuarantinedEstate struct {
+	ID            int64
+	Estate        RealEstate
+	Rules         []string
+	QuarantinedAt time.Time
+}
+
+// quarantineCommand implements "parser quarantine list|release".
+func quarantineCommand(s *Storage, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: quarantine list [-rule RULE] [-source SITE] [-limit N] | quarantine release ID...")
+	}
+
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("quarantine list", flag.ContinueOnError)
+		rule := fs.String("rule", "", "only estates that violated this rule")
+		source := fs.String("source", "", "only estates of this source, e.g. 4zida.rs")
+		limit := fs.Int("limit", 50, "maximum number of rows, 0 for all")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		estates, err := s.ListQuarantine(*rule, *source, *limit)
+		if err != nil {
+			return err
+		}
+		return printQuarantine(os.Stdout, estates)
+	case "release":
+		if len(args) < 2 {
+			return errors.New("usage: quarantine release ID...")
+		}
+
+		var ids []int64
+		for _, arg := range args[1:] {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid quarantine id %q", arg)
+			}
+			ids = append(ids, id)
+		}
+
+		for _, id := range ids {
+			if err := s.ReleaseQuarantined(id); err != nil {
+				return err
+			}
+			fmt.Printf("released %d\n", id)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown quarantine command %q", args[0])
+	}
+}
+
+func printQuarantine(w io.Writer, estates []QuarantinedEstate) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tQUARANTINED\tSOURCE\tRULES\tPRICE\tSQM\tLINK")
+	for _, q := range estates {
+		e := q.Estate
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d %s\t%d\t%s\n",
+			q.ID, q.QuarantinedAt.Format(time.DateTime), e.Source, strings.Join(q.Rules, ","),
+			e.Price, e.Currency, e.SquareMeter, e.Link)
+	}
+	return tw.Flush()
+}
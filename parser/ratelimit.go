@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// domainLimiter is a token bucket shared by every request to one domain,
+// whichever site, worker or detail pass sends it. On top of the bucket every
+// request waits a random delay so the traffic does not look scripted, and
+// with maxPerHour set no more requests than that go out in any hour.
+type domainLimiter struct {
+	randomDelay time.Duration
+	maxPerHour  int
+
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+	sent   []time.Time // send times of the last hour, when maxPerHour is set
+}
+
+func newDomainLimiter(rate float64, burst int, randomDelay time.Duration) *domainLimiter {
+	return &domainLimiter{rate: rate, burst: float64(burst), randomDelay: randomDelay, tokens: float64(burst)}
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[string]*domainLimiter)
+)
+
+// limiterFor returns the limiter of a domain, created on first use from the
+// policy and the domain's politeness: a delay between requests lowers the
+// rate and removes the burst.
+func limiterFor(domain string, p FetchPolicy) *domainLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	l, ok := limiters[domain]
+	if !ok {
+		l = newDomainLimiter(p.Rate, p.Burst, p.RandomDelay)
+		if pol := politenessFor(domain); pol != nil {
+			l.slowDown(pol.delay)
+			l.maxPerHour = pol.MaxRequestsPerHour
+		}
+		limiters[domain] = l
+	}
+	return l
+}
+
+// slowDown makes the limiter leave at least delay between requests, as a
+// politeness delay or a robots.txt Crawl-delay asks: the burst goes, and the
+// rate drops to one request per delay unless it is lower already.
+func (l *domainLimiter) slowDown(delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rate = min(l.rate, 1/delay.Seconds())
+	l.burst = 1
+	l.tokens = min(l.tokens, 1)
+}
+
+// reserve takes a token and returns how long the request has to wait for it,
+// random delay included.
+func (l *domainLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	l.tokens--
+
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+
+	if l.maxPerHour > 0 {
+		// Requests still waiting have future send times, so the hour is
+		// counted from the send time of this one.
+		for len(l.sent) > 0 && now.Add(wait).Sub(l.sent[0]) >= time.Hour {
+			l.sent = l.sent[1:]
+		}
+		if len(l.sent) >= l.maxPerHour {
+			wait = max(wait, l.sent[len(l.sent)-l.maxPerHour].Add(time.Hour).Sub(now))
+			for len(l.sent) > 0 && now.Add(wait).Sub(l.sent[0]) >= time.Hour {
+				l.sent = l.sent[1:]
+			}
+		}
+		l.sent = append(l.sent, now.Add(wait))
+	}
+
+	if l.randomDelay > 0 {
+		wait += rand.N(l.randomDelay)
+	}
+	return wait
+}
+
+// Wait blocks until a request may be sent or ctx is cancelled. A cancelled
+// wait does not give its token back.
+func (l *domainLimiter) Wait(ctx context.Context, domain string) error {
+	wait := l.reserve(time.Now())
+	rateLimitWait.WithLabelValues(domain).Add(wait.Seconds())
+	return sleepContext(ctx, wait)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDomainLimiter(t *testing.T) {
+	l := newDomainLimiter(2, 2, 0)
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	// The burst goes out at once, then one request per 500ms.
+	for i, want := range []time.Duration{0, 0, 500 * time.Millisecond, time.Second} {
+		if got := l.reserve(now); got != want {
+			t.Errorf("request %d waits %v; want %v", i+1, got, want)
+		}
+	}
+
+	// After a quiet spell the bucket refills up to the burst only.
+	now = now.Add(time.Minute)
+	for i, want := range []time.Duration{0, 0, 500 * time.Millisecond} {
+		if got := l.reserve(now); got != want {
+			t.Errorf("after a pause, request %d waits %v; want %v", i+1, got, want)
+		}
+	}
+}
+
+func TestDomainLimiterRandomDelay(t *testing.T) {
+	l := newDomainLimiter(100, 100, 50*time.Millisecond)
+	for i := 0; i < 20; i++ {
+		if wait := l.reserve(time.Now()); wait < 0 || wait >= 50*time.Millisecond {
+			t.Fatalf("wait %v outside the random delay", wait)
+		}
+	}
+}
+
+func TestDomainLimiterSlowDown(t *testing.T) {
+	l := newDomainLimiter(2, 3, 0)
+	l.slowDown(2 * time.Second)
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	for i, want := range []time.Duration{0, 2 * time.Second, 4 * time.Second} {
+		if got := l.reserve(now); got != want {
+			t.Errorf("request %d waits %v; want %v", i+1, got, want)
+		}
+	}
+
+	// A shorter delay keeps the slower rate.
+	l.slowDown(time.Second)
+	if l.rate != 0.5 {
+		t.Errorf("rate %v; want 0.5", l.rate)
+	}
+}
+
+func TestDomainLimiterHourlyCap(t *testing.T) {
+	l := newDomainLimiter(100, 100, 0)
+	l.maxPerHour = 3
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if got := l.reserve(now.Add(time.Duration(i) * time.Minute)); got != 0 {
+			t.Errorf("request %d waits %v; want 0", i+1, got)
+		}
+	}
+
+	// The fourth request waits until the first is an hour old, the fifth
+	// until the second is.
+	now = now.Add(10 * time.Minute)
+	if got, want := l.reserve(now), 50*time.Minute; got != want {
+		t.Errorf("request 4 waits %v; want %v", got, want)
+	}
+	if got, want := l.reserve(now), 51*time.Minute; got != want {
+		t.Errorf("request 5 waits %v; want %v", got, want)
+	}
+}
+
+func TestPoliteTransportRateLimit(t *testing.T) {
+	p := FetchPolicy{Rate: 0.1, Burst: 1}
+	sent := 0
+	transport := politeTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent++
+		return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+	}), p)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://limited.test/1", nil)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next request would wait 10s; cancelling gives up on it.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "http://limited.test/2", nil)
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Error("expected the wait to be cancelled")
+	}
+
+	// Another domain has a bucket of its own.
+	req, _ = http.NewRequest(http.MethodGet, "http://other.test/1", nil)
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if sent != 2 {
+		t.Errorf("sent %d requests; want 2", sent)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+)
+
+func rawHTMLEnabled() bool {
+	return os.Getenv("STORE_RAW_HTML") == "true"
+}
+
+// RawHTMLRecord is the archived card HTML of one listing from one run.
+type RawHTMLRecord struct {
+	Link        string
+	RunID       string
+	Source      string
+	ListingType string
+	PageURL     string
+	HTML        []byte // gzip-compressed
+}
+
+func compressHTML(html string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(html)); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompressHTML(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// errStructuredSource is returned for sources read from embedded JSON. Only
+// the card HTML is archived, and the selectors alone would overwrite the
+// embedded values with their own.
+var errStructuredSource = errors.New("listings are read from embedded json, which is not archived")
+
+// reparseCard runs the current card parser of spec over archived card HTML.
+func reparseCard(spec *SiteSpec, r RawHTMLRecord) (RealEstate, error) {
+	if spec.Structured != nil {
+		return RealEstate{}, errStructuredSource
+	}
+
+	html, err := decompressHTML(r.HTML)
+	if err != nil {
+		return RealEstate{}, fmt.Errorf("failed to decompress html of %s: %w", r.Link, err)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return RealEstate{}, fmt.Errorf("failed to parse html of %s: %w", r.Link, err)
+	}
+
+	pageURL, err := url.Parse(r.PageURL)
+	if err != nil {
+		return RealEstate{}, fmt.Errorf("invalid page url %q: %w", r.PageURL, err)
+	}
+
+	card := doc.Find("body").Children().First()
+	if card.Length() == 0 {
+		return RealEstate{}, fmt.Errorf("archived html of %s has no card", r.Link)
+	}
+
+	resp := &colly.Response{Request: &colly.Request{URL: pageURL}}
+	estate := spec.parseCard(colly.NewHTMLElementFromSelectionNode(resp, card, card.Nodes[0], 0))
+	estate.ListingType = r.ListingType
+	return estate, nil
+}
+
+// changedCardFields lists the card fields that differ between the stored
+// and the re-parsed version of a listing.
+func changedCardFields(stored, parsed RealEstate) []string {
+	var fields []string
+	check := func(name string, changed bool) {
+		if changed {
+			fields = append(fields, name)
+		}
+	}
+
+	check("price", stored.Price != parsed.Price)
+	check("currency", stored.Currency != parsed.Currency)
+	check("price_per_sqm", stored.PricePerSquareMeter != parsed.PricePerSquareMeter)
+	check("square_meter", stored.SquareMeter != parsed.SquareMeter)
+	check("city", stored.City != parsed.City)
+	check("district", stored.District != parsed.District)
+	check("municipality", stored.Municipality != parsed.Municipality)
+	check("street", stored.Street != parsed.Street)
+	check("full_location", stored.FullLocation != parsed.FullLocation)
+	check("who_created", stored.WhoCreated != parsed.WhoCreated)
+	check("quantity_room", stored.QuantityRoom != parsed.QuantityRoom)
+	check("floor", stored.Floor != parsed.Floor)
+	check("floor_total", stored.FloorTotal != parsed.FloorTotal)
+	return fields
+}
+
+type reparseOptions struct {
+	RunID  string
+	Source string
+	DryRun bool
+}
+
+// ReparseReport counts re-parsed listings and, per field, the listings whose
+// value changed.
+type ReparseReport struct {
+	Scanned int
+	Updated int
+	Skipped int
+	Failed  int
+	Fields  map[string]int
+}
+
+func (r ReparseReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "scanned: %d, updated: %d, skipped: %d, failed: %d\n", r.Scanned, r.Updated, r.Skipped, r.Failed)
+
+	names := make([]string, 0, len(r.Fields))
+	for name := range r.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %-14s %d\n", name, r.Fields[name])
+	}
+	return b.String()
+}
+
+// runReparse re-parses archived card HTML with the current site specs and
+// writes back every listing whose card fields changed.
+func runReparse(s *Storage, specs []SiteSpec, opts reparseOptions) (ReparseReport, error) {
+	report := ReparseReport{Fields: make(map[string]int)}
+
+	specBySource := make(map[string]*SiteSpec, len(specs))
+	for i := range specs {
+		specBySource[specs[i].Source] = &specs[i]
+	}
+	if spec, ok := specBySource[opts.Source]; ok && spec.Structured != nil {
+		return report, fmt.Errorf("cannot re-parse %s: %w", opts.Source, errStructuredSource)
+	}
+
+	err := s.EachRawHTML(opts.RunID, opts.Source, func(r RawHTMLRecord) error {
+		report.Scanned++
+
+		spec, ok := specBySource[r.Source]
+		if !ok {
+			slog.Warn("No site spec for archived html", "source", r.Source, "link", r.Link)
+			report.Failed++
+			return nil
+		}
+
+		parsed, err := reparseCard(spec, r)
+		if errors.Is(err, errStructuredSource) {
+			report.Skipped++
+			return nil
+		}
+		if err != nil {
+			slog.Error("Failed to re-parse listing", "link", r.Link, "error", err)
+			report.Failed++
+			return nil
+		}
+		// The link is the key of the archive; keep it even if the selector
+		// for it changed.
+		parsed.Link = r.Link
+
+		stored, err := s.GetEstate(r.Link)
+		if err != nil {
+			slog.Error("Failed to load listing for re-parse", "link", r.Link, "error", err)
+			report.Failed++
+			return nil
+		}
+
+		changed := changedCardFields(stored, parsed)
+		if len(changed) == 0 {
+			return nil
+		}
+
+		if !opts.DryRun {
+			if err := s.UpdateCardFields(parsed); err != nil {
+				slog.Error("Failed to update re-parsed listing", "link", r.Link, "error", err)
+				report.Failed++
+				return nil
+			}
+		}
+
+		report.Updated++
+		for _, field := range changed {
+			report.Fields[field]++
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if report.Updated > 0 && !opts.DryRun {
+		runDeduplication(context.Background(), s)
+	}
+	return report, nil
+}
+
+// reparseCommand implements "parser reparse [-run ID] [-source SITE] [-dry-run]".
+func reparseCommand(s *Storage, specs []SiteSpec, args []string) error {
+	fs := flag.NewFlagSet("reparse", flag.ContinueOnError)
+	var opts reparseOptions
+	fs.StringVar(&opts.RunID, "run", "", "re-parse only the HTML archived by this run (default: latest per listing)")
+	fs.StringVar(&opts.Source, "source", "", "re-parse only listings of this source, e.g. 4zida.rs")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "report changes without writing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return errors.New("reparse takes no positional arguments")
+	}
+
+	report, err := runReparse(s, specs, opts)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Re-parse completed", "scanned", report.Scanned, "updated", report.Updated, "skipped", report.Skipped, "failed", report.Failed, "dry_run", opts.DryRun)
+	fmt.Print(report)
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReparseArchivedCards(t *testing.T) {
+	t.Setenv("STORE_RAW_HTML", "true")
+
+	cases := []struct {
+		site    string
+		fixture string
+		pageURL string
+	}{
+		{"4zida.rs", "4zida_list.html", "https://www.4zida.rs/prodaja-stanova/beograd"},
+		{"halooglasi.com", "halooglasi_list.html", "https://www.halooglasi.com/nekretnine/prodaja-stanova/beograd"},
+		{"nekretnine.rs", "nekretnine_list.html", "https://www.nekretnine.rs/stambeni-objekti/stanovi/izdavanje-prodaja/prodaja/grad/beograd/lista/"},
+		{"cityexpert.rs", "cityexpert_list.html", "https://cityexpert.rs/prodaja-nekretnina/beograd?ptId=1"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.site, func(t *testing.T) {
+			site := testSite(t, c.site)
+			// Only the card selectors can be re-run on archived HTML.
+			selectorsOnly := *site.Spec
+			selectorsOnly.Structured = nil
+			site.Spec = &selectorsOnly
+
+			estates, _ := site.parsePage(loadFixture(t, c.fixture, c.pageURL))
+			if len(estates) == 0 {
+				t.Fatal("no estates parsed")
+			}
+
+			for _, original := range estates {
+				if original.RawHTML == "" {
+					t.Fatalf("raw html of %s was not kept", original.Link)
+				}
+				html, err := compressHTML(original.RawHTML)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				parsed, err := reparseCard(site.Spec, RawHTMLRecord{
+					Link:        original.Link,
+					Source:      original.Source,
+					ListingType: original.ListingType,
+					PageURL:     original.PageURL,
+					HTML:        html,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if fields := changedCardFields(original, parsed); len(fields) > 0 {
+					t.Errorf("re-parse of %s changed %v", original.Link, fields)
+				}
+				if parsed.Link != original.Link {
+					t.Errorf("link = %s; want %s", parsed.Link, original.Link)
+				}
+			}
+		})
+	}
+}
+
+func TestReparseCorrectsStoredRow(t *testing.T) {
+	t.Setenv("STORE_RAW_HTML", "true")
+
+	site := testSite(t, "cityexpert.rs")
+	estates, _ := site.parsePage(loadFixture(t, "cityexpert_list.html", "https://cityexpert.rs/prodaja-nekretnina/beograd?ptId=1"))
+	html, err := compressHTML(estates[0].RawHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a row stored by an older parser that missed the room count.
+	stored := estates[0]
+	stored.QuantityRoom = 0
+	stored.Floor = -5
+
+	parsed, err := reparseCard(site.Spec, RawHTMLRecord{Link: stored.Link, PageURL: stored.PageURL, HTML: html})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := changedCardFields(stored, parsed)
+	want := []string{"quantity_room", "floor"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changed fields = %v; want %v", got, want)
+	}
+}
+
+func TestReparseStructuredSource(t *testing.T) {
+	t.Setenv("STORE_RAW_HTML", "true")
+
+	site := testSite(t, "halooglasi.com")
+	estates, _ := site.parsePage(loadFixture(t, "halooglasi_list.html", "https://www.halooglasi.com/nekretnine/prodaja-stanova/beograd"))
+	if len(estates) == 0 {
+		t.Fatal("no estates parsed")
+	}
+	html, err := compressHTML(estates[0].RawHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The selectors alone would replace the embedded values with their own,
+	// so the listing is skipped rather than re-parsed.
+	if _, err := reparseCard(site.Spec, RawHTMLRecord{Link: estates[0].Link, PageURL: estates[0].PageURL, HTML: html}); !errors.Is(err, errStructuredSource) {
+		t.Errorf("err = %v; want %v", err, errStructuredSource)
+	}
+
+	// Asking for the source alone is refused before any listing is read.
+	if _, err := runReparse(nil, []SiteSpec{*site.Spec}, reparseOptions{Source: site.Source}); !errors.Is(err, errStructuredSource) {
+		t.Errorf("runReparse err = %v; want %v", err, errStructuredSource)
+	}
+}
+
+func TestReparseReportString(t *testing.T) {
+	report := ReparseReport{Scanned: 4, Updated: 2, Skipped: 1, Failed: 1, Fields: map[string]int{"floor": 2, "city": 1}}
+	want := "scanned: 4, updated: 2, skipped: 1, failed: 1\n  city           1\n  floor          2\n"
+	if got := report.String(); got != want {
+		t.Errorf("report =\n%q\nwant\n%q", got, want)
+	}
+}
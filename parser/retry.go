@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// HTTPStatusError is a page answered with a status other than 200.
+type HTTPStatusError struct {
+	Domain     string
+	URL        string
+	StatusCode int
+	// RetryAfter is the delay asked for by a Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("request failed for %s: %d %s", e.Domain, e.StatusCode, e.URL)
+}
+
+func newHTTPStatusError(domain string, r *colly.Response) *HTTPStatusError {
+	e := &HTTPStatusError{Domain: domain, URL: r.Request.URL.String(), StatusCode: r.StatusCode}
+	if r.Headers != nil {
+		e.RetryAfter = parseRetryAfter(r.Headers.Get("Retry-After"), time.Now())
+	}
+	return e
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP
+// date.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// FetchPolicy controls how fast pages are fetched, how listing page fetches
+// are retried and when a site's circuit breaker opens.
+type FetchPolicy struct {
+	Workers     int           // listing pages of a site fetched at the same time
+	Rate        float64       // requests per second to one domain
+	Burst       int           // requests to one domain sent without waiting
+	RandomDelay time.Duration // upper bound of the random delay added to every request
+
+	Retries   int           // retries after the first attempt
+	BaseDelay time.Duration // delay before the first retry, doubled for every further one
+	MaxDelay  time.Duration // cap of the backoff and of Retry-After
+
+	BreakerThreshold int           // consecutive failed pages that open the breaker
+	BreakerCooldown  time.Duration // how long an open breaker pauses the site
+}
+
+var defaultFetchPolicy = FetchPolicy{
+	Workers:          3,
+	Rate:             1,
+	Burst:            3,
+	RandomDelay:      time.Second,
+	Retries:          3,
+	BaseDelay:        2 * time.Second,
+	MaxDelay:         time.Minute,
+	BreakerThreshold: 3,
+	BreakerCooldown:  5 * time.Minute,
+}
+
+// fetchPolicy is set once at startup by configureFetchPolicy.
+var fetchPolicy = defaultFetchPolicy
+
+// configureFetchPolicy reads FETCH_WORKERS, FETCH_RATE, FETCH_BURST,
+// FETCH_RANDOM_DELAY, FETCH_RETRIES, FETCH_RETRY_BASE_DELAY,
+// FETCH_RETRY_MAX_DELAY, BREAKER_THRESHOLD and BREAKER_COOLDOWN.
+func configureFetchPolicy() error {
+	p := defaultFetchPolicy
+
+	ints := map[string]*int{
+		"FETCH_WORKERS":     &p.Workers,
+		"FETCH_BURST":       &p.Burst,
+		"FETCH_RETRIES":     &p.Retries,
+		"BREAKER_THRESHOLD": &p.BreakerThreshold,
+	}
+	for name, dst := range ints {
+		if raw := os.Getenv(name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid %s %q", name, raw)
+			}
+			*dst = n
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"FETCH_RETRY_BASE_DELAY": &p.BaseDelay,
+		"FETCH_RETRY_MAX_DELAY":  &p.MaxDelay,
+		"BREAKER_COOLDOWN":       &p.BreakerCooldown,
+	}
+	for name, dst := range durations {
+		if raw := os.Getenv(name); raw != "" {
+			d, err := time.ParseDuration(raw)
+			if err != nil || d <= 0 {
+				return fmt.Errorf("invalid %s %q", name, raw)
+			}
+			*dst = d
+		}
+	}
+	if p.Workers < 1 || p.Burst < 1 {
+		return errors.New("FETCH_WORKERS and FETCH_BURST must be at least 1")
+	}
+
+	if raw := os.Getenv("FETCH_RATE"); raw != "" {
+		rate, err := strconv.ParseFloat(raw, 64)
+		if err != nil || rate <= 0 {
+			return fmt.Errorf("invalid FETCH_RATE %q", raw)
+		}
+		p.Rate = rate
+	}
+	if raw := os.Getenv("FETCH_RANDOM_DELAY"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid FETCH_RANDOM_DELAY %q", raw)
+		}
+		p.RandomDelay = d
+	}
+
+	if p.BaseDelay > p.MaxDelay {
+		return errors.New("FETCH_RETRY_BASE_DELAY must not exceed FETCH_RETRY_MAX_DELAY")
+	}
+
+	fetchPolicy = p
+	return nil
+}
+
+// backoff is the delay before the given retry (1 for the first): the base
+// delay doubled per retry, capped, of which a random half is dropped so
+// sites failing together do not retry in lockstep.
+func (p FetchPolicy) backoff(retry int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < retry && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxDelay)
+	if half := d / 2; half > 0 {
+		d = half + rand.N(half)
+	}
+	return d
+}
+
+// retryReason tells whether err is worth another attempt and under which
+// metric reason. Server errors, 429 and network errors are retried; other
+// statuses, pages missing from a replay archive, requests refused by the
+// politeness policy and cancellation are not.
+func retryReason(err error) (string, bool) {
+	var status *HTTPStatusError
+	var refused *PolitenessError
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded), errors.Is(err, ErrNotArchived):
+		return "", false
+	case errors.As(err, &refused):
+		return "", false
+	case errors.As(err, &status):
+		if status.StatusCode == http.StatusTooManyRequests || status.StatusCode >= 500 {
+			return strconv.Itoa(status.StatusCode), true
+		}
+		return "", false
+	}
+	return "network", true
+}
+
+// retryDelay is how long to wait before the given retry after err. A
+// Retry-After on 429 or 503 replaces the backoff, up to MaxDelay.
+func (p FetchPolicy) retryDelay(retry int, err error) time.Duration {
+	var status *HTTPStatusError
+	if errors.As(err, &status) && status.RetryAfter > 0 &&
+		(status.StatusCode == http.StatusTooManyRequests || status.StatusCode == http.StatusServiceUnavailable) {
+		return min(status.RetryAfter, p.MaxDelay)
+	}
+	return p.backoff(retry)
+}
+
+// listWithRetry fetches a listing page, retrying failures that may go away
+// with backoff.
+func listWithRetry(ctx context.Context, site Site, page int, p FetchPolicy) ([]RealEstate, PageInfo, error) {
+	for retry := 1; ; retry++ {
+		estates, info, err := site.ListContext(ctx, page)
+		if err == nil {
+			return estates, info, nil
+		}
+
+		reason, ok := retryReason(err)
+		if !ok || retry > p.Retries || ctx.Err() != nil {
+			return nil, PageInfo{}, err
+		}
+
+		delay := p.retryDelay(retry, err)
+		slog.Warn("Retrying page", "site", site.Name, "page", page, "retry", retry, "delay", delay, "error", err)
+		fetchRetries.WithLabelValues(site.Name, reason).Inc()
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, PageInfo{}, err
+		}
+	}
+}
+
+// sleepContext sleeps for d or until ctx is cancelled.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
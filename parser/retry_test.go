@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubTransport answers listing requests with the given statuses in turn,
+// then with the 4zida fixture, and counts the requests. Like replay mode it
+// skips politeness delays.
+func stubTransport(t *testing.T, statuses ...int) *int {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", "4zida_list.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := 0
+	httpMode = HTTPModeReplay
+	httpTransport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		status, content := http.StatusOK, string(body)
+		header := http.Header{"Content-Type": {"text/html; charset=utf-8"}}
+		if requests <= len(statuses) {
+			status, content = statuses[requests-1], "busy"
+			header.Set("Retry-After", "0")
+		}
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+			StatusCode: status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     header,
+			Body:       io.NopCloser(strings.NewReader(content)),
+			Request:    req,
+		}, nil
+	})
+	t.Cleanup(func() { configureHTTPMode(HTTPModeLive, "") })
+	return &requests
+}
+
+var testFetchPolicy = FetchPolicy{Retries: 3, BaseDelay: time.Millisecond, MaxDelay: 4 * time.Millisecond}
+
+func TestListWithRetry(t *testing.T) {
+	site := testSite(t, "4zida.rs")
+
+	requests := stubTransport(t, http.StatusServiceUnavailable, http.StatusBadGateway)
+	estates, _, err := listWithRetry(context.Background(), site, 1, testFetchPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(estates) != 2 || *requests != 3 {
+		t.Errorf("got %d estates after %d requests; want 2 after 3", len(estates), *requests)
+	}
+
+	requests = stubTransport(t, 500, 500, 500, 500, 500)
+	_, _, err = listWithRetry(context.Background(), site, 1, testFetchPolicy)
+	var status *HTTPStatusError
+	if !errors.As(err, &status) || status.StatusCode != 500 {
+		t.Errorf("expected a 500 status error, got %v", err)
+	}
+	if *requests != 4 {
+		t.Errorf("made %d requests; want 1 + 3 retries", *requests)
+	}
+
+	requests = stubTransport(t, http.StatusNotFound)
+	if _, _, err := listWithRetry(context.Background(), site, 1, testFetchPolicy); err == nil {
+		t.Error("expected an error for 404")
+	}
+	if *requests != 1 {
+		t.Errorf("404 was retried: %d requests", *requests)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := FetchPolicy{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	for retry, max := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 8: 10 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if d := p.retryDelay(retry, errors.New("connection reset")); d < max/2 || d >= max {
+				t.Fatalf("retry %d: delay %v outside [%v, %v)", retry, d, max/2, max)
+			}
+		}
+	}
+
+	busy := &HTTPStatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 7 * time.Second}
+	if d := p.retryDelay(1, busy); d != 7*time.Second {
+		t.Errorf("Retry-After 7s: delay %v", d)
+	}
+	busy.RetryAfter = time.Hour
+	if d := p.retryDelay(1, busy); d != p.MaxDelay {
+		t.Errorf("Retry-After 1h: delay %v; want the cap", d)
+	}
+}
+
+func TestRetryReason(t *testing.T) {
+	cases := []struct {
+		err    error
+		reason string
+		retry  bool
+	}{
+		{errors.New("connection reset"), "network", true},
+		{&HTTPStatusError{StatusCode: 503}, "503", true},
+		{&HTTPStatusError{StatusCode: 429}, "429", true},
+		{&HTTPStatusError{StatusCode: 404}, "", false},
+		{fmt.Errorf("get: %w", context.Canceled), "", false},
+		{ErrNotArchived, "", false},
+	}
+	for _, c := range cases {
+		if reason, retry := retryReason(c.err); reason != c.reason || retry != c.retry {
+			t.Errorf("retryReason(%v) = %q, %v; want %q, %v", c.err, reason, retry, c.reason, c.retry)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"":                              0,
+		"120":                           2 * time.Minute,
+		"-5":                            0,
+		"Sat, 17 Oct 2026 12:00:30 GMT": 30 * time.Second,
+		"Sat, 17 Oct 2026 11:00:00 GMT": 0,
+		"soon":                          0,
+	}
+	for header, want := range cases {
+		if got := parseRetryAfter(header, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v; want %v", header, got, want)
+		}
+	}
+}
+
+func TestConfigureFetchPolicy(t *testing.T) {
+	t.Cleanup(func() { fetchPolicy = defaultFetchPolicy })
+
+	t.Setenv("FETCH_RETRIES", "5")
+	t.Setenv("BREAKER_COOLDOWN", "1m")
+	if err := configureFetchPolicy(); err != nil {
+		t.Fatal(err)
+	}
+	if fetchPolicy.Retries != 5 || fetchPolicy.BreakerCooldown != time.Minute || fetchPolicy.BaseDelay != defaultFetchPolicy.BaseDelay {
+		t.Errorf("unexpected policy %+v", fetchPolicy)
+	}
+
+	t.Setenv("FETCH_RATE", "0.5")
+	t.Setenv("FETCH_RANDOM_DELAY", "0s")
+	if err := configureFetchPolicy(); err != nil {
+		t.Fatal(err)
+	}
+	if fetchPolicy.Rate != 0.5 || fetchPolicy.RandomDelay != 0 || fetchPolicy.Workers != defaultFetchPolicy.Workers {
+		t.Errorf("unexpected policy %+v", fetchPolicy)
+	}
+
+	for name, raw := range map[string]string{
+		"FETCH_RETRIES": "-1", "FETCH_RETRY_MAX_DELAY": "1s", "BREAKER_COOLDOWN": "often",
+		"FETCH_WORKERS": "0", "FETCH_RATE": "0", "FETCH_RANDOM_DELAY": "-1s",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, raw)
+			if err := configureFetchPolicy(); err == nil {
+				t.Errorf("%s=%s: expected an error", name, raw)
+			}
+		})
+	}
+}
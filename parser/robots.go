@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+)
+
+// Robots modes. enforce refuses requests robots.txt disallows or sent while
+// it is unavailable, report only counts and logs them and ignore does not fetch robots.txt at all.
+const (
+	RobotsEnforce = "enforce"
+	RobotsReport  = "report"
+	RobotsIgnore  = "ignore"
+)
+
+const (
+	defaultRobotsCacheTTL = 24 * time.Hour
+	// robotsErrorTTL is how long an unreachable robots.txt is remembered
+	// before it is fetched again.
+	robotsErrorTTL = time.Minute
+	// maxRobotsSize caps the robots.txt read, as Google does at 500 KiB.
+	maxRobotsSize = 500 << 10
+)
+
+// robotsCache fetches the robots.txt of every host once and keeps it for
+// ttl. A robots.txt that cannot be fetched is an error for robotsErrorTTL;
+// enforce mode then refuses the host's requests, as RFC 9309 asks for
+// unreachable files.
+type robotsCache struct {
+	ttl       time.Duration
+	transport http.RoundTripper
+
+	mu      sync.Mutex
+	entries map[string]*robotsEntry
+}
+
+type robotsEntry struct {
+	ready     chan struct{} // closed once data and err are set
+	data      *robotstxt.RobotsData
+	err       error
+	fetchedAt time.Time
+}
+
+func newRobotsCache(transport http.RoundTripper, ttl time.Duration) *robotsCache {
+	return &robotsCache{ttl: ttl, transport: transport, entries: make(map[string]*robotsEntry)}
+}
+
+// check reports whether userAgent may fetch u and the crawl delay robots.txt
+// asks of it. Requests for the same host wait for one fetch of the file.
+func (c *robotsCache) check(ctx context.Context, u *url.URL, userAgent string) (bool, time.Duration, error) {
+	entry := c.entry(ctx, u)
+	select {
+	case <-entry.ready:
+	case <-ctx.Done():
+		return false, 0, ctx.Err()
+	}
+	if entry.err != nil {
+		return false, 0, entry.err
+	}
+	return entry.data.TestAgent(u.RequestURI(), userAgent), entry.data.FindGroup(userAgent).CrawlDelay, nil
+}
+
+func (c *robotsCache) entry(ctx context.Context, u *url.URL) *robotsEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := u.Scheme + "://" + u.Host
+	if entry, ok := c.entries[key]; ok && !entry.stale(c.ttl, time.Now()) {
+		return entry
+	}
+
+	entry := &robotsEntry{ready: make(chan struct{})}
+	c.entries[key] = entry
+	go func() {
+		defer close(entry.ready)
+		// Other requests may be waiting for this fetch, so it outlives the
+		// request that started it.
+		entry.data, entry.err = c.fetch(context.WithoutCancel(ctx), key+"/robots.txt")
+		entry.fetchedAt = time.Now()
+	}()
+	return entry
+}
+
+// stale reports whether an entry should be fetched again. Entries still
+// being fetched are not.
+func (e *robotsEntry) stale(ttl time.Duration, now time.Time) bool {
+	select {
+	case <-e.ready:
+	default:
+		return false
+	}
+	if e.err != nil {
+		ttl = robotsErrorTTL
+	}
+	return now.Sub(e.fetchedAt) >= ttl
+}
+
+func (c *robotsCache) fetch(ctx context.Context, robotsURL string) (*robotstxt.RobotsData, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	client := &http.Client{Transport: c.transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", robotsURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", robotsURL, err)
+	}
+	// 4xx allows everything and 5xx disallows everything.
+	data, err := robotstxt.FromStatusAndBytes(resp.StatusCode, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", robotsURL, err)
+	}
+	return data, nil
+}
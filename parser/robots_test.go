@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// robotsServer serves body with status as every robots.txt and counts the
+// fetches.
+func robotsServer(status int, body string, fetches *atomic.Int32) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		fetches.Add(1)
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+	})
+}
+
+func TestRobotsCache(t *testing.T) {
+	var fetches atomic.Int32
+	cache := newRobotsCache(robotsServer(http.StatusOK, `
+User-agent: *
+Disallow: /admin
+
+User-agent: BelgradeEstateML-parser
+Disallow: /search?sort=
+Crawl-delay: 5
+`, &fetches), time.Hour)
+
+	for _, tc := range []struct {
+		url, agent string
+		allowed    bool
+		delay      time.Duration
+	}{
+		{"https://a.test/prodaja?strana=2", defaultUserAgent, true, 5 * time.Second},
+		{"https://a.test/search?sort=price", defaultUserAgent, false, 5 * time.Second},
+		{"https://a.test/admin", defaultUserAgent, true, 5 * time.Second},
+		{"https://a.test/admin", "SomeBot/2.0", false, 0},
+	} {
+		u, _ := url.Parse(tc.url)
+		allowed, delay, err := cache.check(context.Background(), u, tc.agent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != tc.allowed || delay != tc.delay {
+			t.Errorf("%s as %s: allowed %v, delay %v; want %v, %v", tc.url, tc.agent, allowed, delay, tc.allowed, tc.delay)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetched robots.txt %d times; want 1", n)
+	}
+}
+
+func TestRobotsCacheSingleFetch(t *testing.T) {
+	var fetches atomic.Int32
+	cache := newRobotsCache(robotsServer(http.StatusOK, "User-agent: *\nAllow: /\n", &fetches), time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u, _ := url.Parse("https://b.test/page")
+			if _, _, err := cache.check(context.Background(), u, defaultUserAgent); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	u, _ := url.Parse("https://c.test/page")
+	if _, _, err := cache.check(context.Background(), u, defaultUserAgent); err != nil {
+		t.Fatal(err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("fetched robots.txt %d times for two hosts; want 2", n)
+	}
+}
+
+func TestRobotsCacheStatus(t *testing.T) {
+	for _, tc := range []struct {
+		status  int
+		allowed bool
+	}{
+		{http.StatusNotFound, true},
+		{http.StatusServiceUnavailable, false},
+	} {
+		var fetches atomic.Int32
+		cache := newRobotsCache(robotsServer(tc.status, "", &fetches), time.Hour)
+		u, _ := url.Parse("https://d.test/page")
+		allowed, _, err := cache.check(context.Background(), u, defaultUserAgent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != tc.allowed {
+			t.Errorf("status %d: allowed %v; want %v", tc.status, allowed, tc.allowed)
+		}
+	}
+}
+
+func TestRobotsEntryStale(t *testing.T) {
+	now := time.Now()
+	entry := &robotsEntry{ready: make(chan struct{}), fetchedAt: now}
+	if entry.stale(time.Hour, now.Add(2*time.Hour)) {
+		t.Error("an entry being fetched should not be stale")
+	}
+	close(entry.ready)
+	if entry.stale(time.Hour, now.Add(30*time.Minute)) {
+		t.Error("an entry within its ttl should not be stale")
+	}
+	if !entry.stale(time.Hour, now.Add(time.Hour)) {
+		t.Error("an entry past its ttl should be stale")
+	}
+
+	entry.err = io.ErrUnexpectedEOF
+	if !entry.stale(time.Hour, now.Add(robotsErrorTTL)) {
+		t.Error("a failed fetch should be retried after robotsErrorTTL")
+	}
+}
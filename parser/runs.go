@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Termination reasons of a site run. A run without one is still going or the
+// process died. Such runs and interrupted ones keep their checkpoint and
+// resume on the next start.
+const (
+	TerminationCompleted   = "completed"    // the site ran out of pages or the reported total was reached
+	TerminationMaxPages    = "max_pages"    // stopped at the spec's page limit
+	TerminationFetchError  = "fetch_error"  // a listing page could not be fetched
+	TerminationCircuitOpen = "circuit_open" // the site kept failing after a circuit breaker pause
+	TerminationDegraded    = "degraded"     // fill rates collapsed
+	TerminationPageRange   = "page_range"   // a scrape command reached its last requested page
+	TerminationCancelled   = "cancelled"    // cancelled through the admin API
+	TerminationInterrupted = "interrupted"  // the parser was shutting down
+	TerminationCaughtUp    = "caught_up"    // an incremental run reached listings already stored
+)
+
+// errRunCancelled is the cancellation cause of a run cancelled through the
+// admin API. Any other cancellation is a shutdown.
+var errRunCancelled = errors.New("run cancelled")
+
+// stopReason is the termination of a run whose context was cancelled.
+func stopReason(ctx context.Context) string {
+	if errors.Is(context.Cause(ctx), errRunCancelled) {
+		return TerminationCancelled
+	}
+	return TerminationInterrupted
+}
+
+// SiteRun is the bookkeeping of one site within a run, stored in scrape_runs.
+type SiteRun struct {
+	RunID            string         `json:"run_id"`
+	Site             string         `json:"site"`
+	Source           string         `json:"source"`
+	ListingType      string         `json:"listing_type"`
+	StartedAt        time.Time      `json:"started_at"`
+	FinishedAt       *time.Time     `json:"finished_at"`
+	Resumed          bool           `json:"resumed"`
+	Pages            int            `json:"pages"`
+	ItemsInserted    int            `json:"items_inserted"`
+	ItemsUpdated     int            `json:"items_updated"`
+	ItemsUnchanged   int            `json:"items_unchanged"`
+	ItemsFailed      int            `json:"items_failed"`
+	ItemsQuarantined int            `json:"items_quarantined"`
+	ItemsSkipped     int            `json:"items_skipped"`
+	Errors           map[string]int `json:"errors"`
+	Termination      string         `json:"termination,omitempty"`
+	Mode             string         `json:"mode"`
+	// ReportedItems is the size of the listing as reported by its first
+	// page: the announced total, or the linked pages times the page size.
+	ReportedItems int `json:"reported_items"`
+}
+
+func newSiteRun(runID string, site Site, startedAt time.Time) *SiteRun {
+	return &SiteRun{
+		RunID:       runID,
+		Site:        site.Name,
+		Source:      site.Source,
+		ListingType: listingTypeOrSale(site.ListingType),
+		StartedAt:   startedAt,
+		Errors:      make(map[string]int),
+		Mode:        RunModeFull,
+	}
+}
+
+// countItems records n cards with a parser_items_processed_total status.
+func (r *SiteRun) countItems(status string, n int) {
+	processedItems.WithLabelValues(r.Site, status).Add(float64(n))
+
+	switch SaveOutcome(status) {
+	case SaveInserted:
+		r.ItemsInserted += n
+	case SaveUpdated:
+		r.ItemsUpdated += n
+	case SaveUnchanged:
+		r.ItemsUnchanged += n
+	case SaveFailed:
+		r.ItemsFailed += n
+	}
+	switch status {
+	case "quarantined":
+		r.ItemsQuarantined += n
+	case "skipped":
+		r.ItemsSkipped += n
+	}
+}
+
+// countError records an error of a parser_errors_total phase.
+func (r *SiteRun) countError(phase string) {
+	parserErrors.WithLabelValues(r.Site, phase).Inc()
+	r.Errors[phase]++
+}
+
+func (r *SiteRun) finish(termination string, at time.Time) {
+	r.Termination = termination
+	r.FinishedAt = &at
+}
+
+// runsHandler serves GET /runs?site=...&limit=50: the most recent site runs,
+// newest first.
+func runsHandler(list func(ctx context.Context, site string, limit int) ([]SiteRun, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		limit := 50
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 || n > 1000 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "limit must be between 1 and 1000"})
+				return
+			}
+			limit = n
+		}
+
+		runs, err := list(r.Context(), r.URL.Query().Get("site"), limit)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if runs == nil {
+			runs = []SiteRun{}
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"count": len(runs),
+			"runs":  runs,
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSiteRunCounters(t *testing.T) {
+	start := time.Date(2026, 10, 17, 4, 0, 0, 0, time.UTC)
+	run := newSiteRun("20261017T040000Z", Site{Name: "4zida.rs/rent", Source: "4zida.rs", ListingType: ListingRent}, start)
+
+	run.countItems(string(SaveInserted), 3)
+	run.countItems(string(SaveUpdated), 2)
+	run.countItems(string(SaveUnchanged), 15)
+	run.countItems(string(SaveFailed), 1)
+	run.countItems("quarantined", 1)
+	run.countItems("skipped", 20)
+	run.countError("detail_fetch")
+	run.countError("detail_fetch")
+	run.finish(TerminationDegraded, start.Add(time.Hour))
+
+	want := &SiteRun{
+		RunID:            "20261017T040000Z",
+		Site:             "4zida.rs/rent",
+		Source:           "4zida.rs",
+		ListingType:      ListingRent,
+		StartedAt:        start,
+		FinishedAt:       run.FinishedAt,
+		ItemsInserted:    3,
+		ItemsUpdated:     2,
+		ItemsUnchanged:   15,
+		ItemsFailed:      1,
+		ItemsQuarantined: 1,
+		ItemsSkipped:     20,
+		Errors:           map[string]int{"detail_fetch": 2},
+		Termination:      TerminationDegraded,
+		Mode:             RunModeFull,
+	}
+	if !reflect.DeepEqual(run, want) {
+		t.Errorf("run = %+v\nwant %+v", run, want)
+	}
+	if !run.FinishedAt.Equal(start.Add(time.Hour)) {
+		t.Errorf("finished at %v", run.FinishedAt)
+	}
+}
+
+func TestRunsHandler(t *testing.T) {
+	var gotSite string
+	var gotLimit int
+	list := func(_ context.Context, site string, limit int) ([]SiteRun, error) {
+		gotSite, gotLimit = site, limit
+		if site == "broken" {
+			return nil, errors.New("db down")
+		}
+		if site == "new" {
+			return nil, nil
+		}
+		return []SiteRun{{RunID: "20261017T040000Z", Site: site, Errors: map[string]int{}}}, nil
+	}
+	handler := runsHandler(list)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/runs?site=cityexpert.rs&limit=5", nil))
+	if rec.Code != http.StatusOK || gotSite != "cityexpert.rs" || gotLimit != 5 {
+		t.Fatalf("status %d, site %q, limit %d", rec.Code, gotSite, gotLimit)
+	}
+	var body struct {
+		Count int       `json:"count"`
+		Runs  []SiteRun `json:"runs"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Count != 1 || body.Runs[0].FinishedAt != nil || body.Runs[0].Site != "cityexpert.rs" {
+		t.Errorf("body = %+v", body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/runs", nil))
+	if gotLimit != 50 {
+		t.Errorf("default limit = %d; want 50", gotLimit)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/runs?site=new", nil))
+	if got := rec.Body.String(); got != "{\"count\":0,\"runs\":[]}\n" {
+		t.Errorf("empty body = %s", got)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/runs?limit=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("limit=0 status = %d; want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/runs?site=broken", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("db error status = %d; want 500", rec.Code)
+	}
+}
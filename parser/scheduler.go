@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultScheduleJitter spreads runs that share a cron slot so portals are
+// not hit at the same second every day.
+const defaultScheduleJitter = 10 * time.Minute
+
+// scheduleJitter reads SCHEDULE_JITTER, the upper bound of the random delay
+// added to every scheduled run.
+func scheduleJitter() (time.Duration, error) {
+	raw := os.Getenv("SCHEDULE_JITTER")
+	if raw == "" {
+		return defaultScheduleJitter, nil
+	}
+	jitter, err := time.ParseDuration(raw)
+	if err != nil || jitter < 0 {
+		return 0, fmt.Errorf("invalid SCHEDULE_JITTER %q", raw)
+	}
+	return jitter, nil
+}
+
+// Scheduler runs every site on the cron schedule of its spec. A site never
+// runs twice at the same time: a trigger that finds it running is skipped.
+// Cancelling its context stops scheduling and interrupts the running sites.
+type Scheduler struct {
+	ctx         context.Context
+	storage     *Storage
+	sites       []Site
+	jitter      time.Duration
+	withDetails bool
+
+	// run scrapes one site; tests replace it.
+	run func(ctx context.Context, site Site, live *liveRun)
+
+	mu      sync.Mutex
+	running map[string]*liveRun
+	wg      sync.WaitGroup
+
+	// dedupMu serializes deduplication, which runs after every site run.
+	dedupMu sync.Mutex
+}
+
+func NewScheduler(ctx context.Context, s *Storage, sites []Site, jitter time.Duration) *Scheduler {
+	sc := &Scheduler{
+		ctx:         ctx,
+		storage:     s,
+		sites:       sites,
+		jitter:      jitter,
+		withDetails: detailsEnabled(),
+		running:     make(map[string]*liveRun),
+	}
+	sc.run = sc.runSite
+	return sc
+}
+
+// Run schedules every site until the scheduler's context is cancelled and
+// then waits for the running sites to stop. Sites with an interrupted run or
+// a missed slot are started right away.
+func (sc *Scheduler) Run() {
+	if sc.withDetails {
+		slog.Info("Detail page pass enabled")
+	}
+	if rawHTMLEnabled() {
+		slog.Info("Raw HTML archive enabled")
+	}
+
+	now := time.Now()
+	for _, site := range sc.sites {
+		due, reason := sc.overdue(site, now)
+		if due {
+			slog.Info("Site is due, starting now", "site", site.Name, "reason", reason)
+		}
+		go sc.loop(site, due)
+	}
+
+	<-sc.ctx.Done()
+	slog.Info("Scheduler stopped, waiting for running sites")
+	sc.wg.Wait()
+}
+
+// overdue reports whether a site should run at startup: it has a checkpoint
+// to resume, has never run, or its last run is older than its latest slot.
+func (sc *Scheduler) overdue(site Site, now time.Time) (bool, string) {
+	cp, err := sc.storage.LoadCheckpoint(sc.ctx, site.Name)
+	if err != nil {
+		slog.Error("Error loading checkpoint", "site", site.Name, "error", err)
+	}
+	if cp.resumable(now) {
+		return true, "interrupted"
+	}
+
+	runs, err := sc.storage.ListSiteRuns(sc.ctx, site.Name, 1)
+	if err != nil {
+		slog.Error("Error loading run history", "site", site.Name, "error", err)
+		return false, ""
+	}
+	if len(runs) == 0 {
+		return true, "never run"
+	}
+	if next := site.Spec.cron.Next(runs[0].StartedAt); next.Before(now) {
+		return true, "missed slot"
+	}
+	return false, ""
+}
+
+func (sc *Scheduler) loop(site Site, runNow bool) {
+	if runNow {
+		sc.trigger(site)
+	}
+	for {
+		next := site.Spec.cron.Next(time.Now()).Add(sc.randomJitter())
+		nextRunTimestamp.WithLabelValues(site.Name).Set(float64(next.Unix()))
+		slog.Info("Next run scheduled", "site", site.Name, "schedule", site.Spec.cron.String(), "at", next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-sc.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		sc.trigger(site)
+	}
+}
+
+// trigger runs a site unless it is already running and reports whether it
+// ran.
+func (sc *Scheduler) trigger(site Site) bool {
+	ctx, live, ok := sc.reserve(site)
+	if !ok {
+		return false
+	}
+	sc.execute(ctx, site, live)
+	return true
+}
+
+// reserve marks a site as running unless it already is or the scheduler is
+// stopping. The returned context is cancelled by Cancel and on shutdown.
+func (sc *Scheduler) reserve(site Site) (context.Context, *liveRun, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.ctx.Err() != nil {
+		return nil, nil, false
+	}
+	if _, ok := sc.running[site.Name]; ok {
+		slog.Warn("Site is still running, skipping trigger", "site", site.Name)
+		scheduleSkipped.WithLabelValues(site.Name).Inc()
+		return nil, nil, false
+	}
+	ctx, cancel := context.WithCancelCause(sc.ctx)
+	live := newLiveRun(site.Name, func() { cancel(errRunCancelled) })
+	sc.running[site.Name] = live
+	sc.wg.Add(1)
+	return ctx, live, true
+}
+
+func (sc *Scheduler) execute(ctx context.Context, site Site, live *liveRun) {
+	defer func() {
+		live.cancel()
+		sc.mu.Lock()
+		delete(sc.running, site.Name)
+		sc.mu.Unlock()
+		sc.wg.Done()
+	}()
+	sc.run(ctx, site, live)
+}
+
+// Trigger starts the named site, or every site when name is empty, in the
+// background. It returns the sites it started and those already running.
+func (sc *Scheduler) Trigger(name string) (started, running []string, err error) {
+	targets := sc.sites
+	if name != "" {
+		site, ok := siteByName(sc.sites, name)
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown site %q", name)
+		}
+		targets = []Site{site}
+	}
+
+	for _, site := range targets {
+		ctx, live, ok := sc.reserve(site)
+		if !ok {
+			running = append(running, site.Name)
+			continue
+		}
+		slog.Info("Run triggered", "site", site.Name)
+		go sc.execute(ctx, site, live)
+		started = append(started, site.Name)
+	}
+	return started, running, nil
+}
+
+// Cancel cancels the run of the named site, or of every running site when
+// name is empty, and returns the sites it cancelled. Runs stop after the
+// page they are on.
+func (sc *Scheduler) Cancel(name string) []string {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	var cancelled []string
+	for site, live := range sc.running {
+		if name != "" && site != name {
+			continue
+		}
+		slog.Info("Run cancel requested", "site", site)
+		live.cancel()
+		cancelled = append(cancelled, site)
+	}
+	sort.Strings(cancelled)
+	return cancelled
+}
+
+// Progress returns the progress of every running site, ordered by site.
+func (sc *Scheduler) Progress() []SiteProgress {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	progress := make([]SiteProgress, 0, len(sc.running))
+	for _, live := range sc.running {
+		progress = append(progress, live.snapshot())
+	}
+	sort.Slice(progress, func(i, j int) bool { return progress[i].Site < progress[j].Site })
+	return progress
+}
+
+// runSite scrapes a site under a run id of its own and deduplicates.
+func (sc *Scheduler) runSite(ctx context.Context, site Site, live *liveRun) {
+	runSite(ctx, sc.storage, site, time.Now().UTC().Format("20060102T150405Z"), sc.withDetails, live)
+	if ctx.Err() != nil {
+		return
+	}
+
+	sc.dedupMu.Lock()
+	defer sc.dedupMu.Unlock()
+	runDeduplication(ctx, sc.storage)
+}
+
+func (sc *Scheduler) randomJitter() time.Duration {
+	if sc.jitter <= 0 {
+		return 0
+	}
+	return rand.N(sc.jitter)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedulerSkipsOverlappingRuns(t *testing.T) {
+	site := testSite(t, "4zida.rs")
+	sc := NewScheduler(context.Background(), nil, []Site{site}, 0)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	runs := 0
+	sc.run = func(context.Context, Site, *liveRun) {
+		runs++
+		close(started)
+		<-release
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if !sc.trigger(site) {
+			t.Error("first trigger did not run")
+		}
+	}()
+	<-started
+
+	if sc.trigger(site) {
+		t.Error("second trigger ran while the site was running")
+	}
+	close(release)
+	wg.Wait()
+
+	if runs != 1 {
+		t.Errorf("site ran %d times; want 1", runs)
+	}
+	if _, ok := sc.running[site.Name]; ok {
+		t.Error("site is still marked running")
+	}
+}
+
+func TestScheduleJitter(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":    defaultScheduleJitter,
+		"0s":  0,
+		"30m": 30 * time.Minute,
+	}
+	for raw, want := range cases {
+		t.Setenv("SCHEDULE_JITTER", raw)
+		got, err := scheduleJitter()
+		if err != nil || got != want {
+			t.Errorf("SCHEDULE_JITTER=%q: got %v, %v; want %v", raw, got, err, want)
+		}
+	}
+
+	for _, raw := range []string{"soon", "-5m"} {
+		t.Setenv("SCHEDULE_JITTER", raw)
+		if _, err := scheduleJitter(); err == nil {
+			t.Errorf("SCHEDULE_JITTER=%q: expected an error", raw)
+		}
+	}
+
+	sc := &Scheduler{jitter: time.Minute}
+	for i := 0; i < 100; i++ {
+		if j := sc.randomJitter(); j < 0 || j >= time.Minute {
+			t.Fatalf("jitter %v outside [0, 1m)", j)
+		}
+	}
+}
+
+func TestSchedulerShutdown(t *testing.T) {
+	ctx, shutdown := context.WithCancel(context.Background())
+	sites := []Site{testSite(t, "4zida.rs"), testSite(t, "halooglasi.com")}
+	sc := NewScheduler(ctx, nil, sites, 0)
+
+	started := make(chan struct{}, 2)
+	reasons := make(chan string, 2)
+	sc.run = func(ctx context.Context, site Site, live *liveRun) {
+		started <- struct{}{}
+		<-ctx.Done()
+		reasons <- site.Name + " " + stopReason(ctx)
+	}
+
+	if started, _, err := sc.Trigger(""); err != nil || len(started) != 2 {
+		t.Fatalf("Trigger = %v, %v", started, err)
+	}
+	<-started
+	<-started
+
+	sc.Cancel("4zida.rs")
+	if got := <-reasons; got != "4zida.rs "+TerminationCancelled {
+		t.Errorf("cancelled run stopped as %q", got)
+	}
+
+	shutdown()
+	if got := <-reasons; got != "halooglasi.com "+TerminationInterrupted {
+		t.Errorf("running site stopped as %q", got)
+	}
+	sc.wg.Wait()
+
+	if started, _, _ := sc.Trigger("4zida.rs"); len(started) != 0 {
+		t.Error("a site started after shutdown")
+	}
+}
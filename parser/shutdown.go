@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// defaultShutdownGracePeriod stays below the 30s stop_grace_period of
+// docker-compose.yml, so the parser exits on its own before Docker kills it.
+const defaultShutdownGracePeriod = 25 * time.Second
+
+// shutdownGracePeriod reads SHUTDOWN_GRACE_PERIOD, how long the parser may
+// take to finish the current pages after SIGTERM or SIGINT.
+func shutdownGracePeriod() (time.Duration, error) {
+	raw := os.Getenv("SHUTDOWN_GRACE_PERIOD")
+	if raw == "" {
+		return defaultShutdownGracePeriod, nil
+	}
+	grace, err := time.ParseDuration(raw)
+	if err != nil || grace <= 0 {
+		return 0, fmt.Errorf("invalid SHUTDOWN_GRACE_PERIOD %q", raw)
+	}
+	return grace, nil
+}
+
+// shutdownContext returns a context cancelled by SIGTERM or SIGINT. Once it
+// is, a second signal kills the process right away and the process exits
+// anyway when the grace period runs out.
+func shutdownContext(grace time.Duration) context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+
+	go func() {
+		<-ctx.Done()
+		stop()
+		slog.Warn("Shutdown requested, stopping after the current pages", "grace_period", grace)
+
+		time.Sleep(grace)
+		slog.Error("Grace period expired, exiting with work in flight")
+		os.Exit(1)
+	}()
+	return ctx
+}
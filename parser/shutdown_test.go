@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShutdownGracePeriod(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":    defaultShutdownGracePeriod,
+		"10s": 10 * time.Second,
+	}
+	for raw, want := range cases {
+		t.Setenv("SHUTDOWN_GRACE_PERIOD", raw)
+		got, err := shutdownGracePeriod()
+		if err != nil || got != want {
+			t.Errorf("SHUTDOWN_GRACE_PERIOD=%q: got %v, %v; want %v", raw, got, err, want)
+		}
+	}
+
+	for _, raw := range []string{"0s", "-1s", "later"} {
+		t.Setenv("SHUTDOWN_GRACE_PERIOD", raw)
+		if _, err := shutdownGracePeriod(); err == nil {
+			t.Errorf("SHUTDOWN_GRACE_PERIOD=%q: expected an error", raw)
+		}
+	}
+}
+
+func TestListContextAbortsRequest(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	site := testSiteWithURLs(t, "4zida.rs", server.URL+"/stanovi", server.URL+"/stanovi?strana=%d")
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	if _, _, err := site.ListContext(ctx, 1); err == nil {
+		t.Fatal("expected an error from the aborted request")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("request took %v after cancellation", elapsed)
+	}
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+)
+
+// Built-in site definitions. SITES_DIR replaces them with the *.json files
+// of another directory, so a portal can be added without rebuilding.
+//
+//go:embed sites/*.json
+var embeddedSiteSpecs embed.FS
+
+// SiteSpec declares how to scrape one portal: where its listing pages are,
+// which element is one listing card and how card fields map to RealEstate.
+type SiteSpec struct {
+	Name              string                 `json:"name"`
+	Source            string                 `json:"source"`
+	Listings          map[string]ListingURLs `json:"listings"` // keyed by listing type
+	CardSelector      string                 `json:"card_selector"`
+	Fields            []FieldSpec            `json:"fields"`
+	DerivePricePerSqm bool                   `json:"derive_price_per_sqm,omitempty"`
+	Pagination        PaginationSpec         `json:"pagination"`
+	Details           []DetailSelector       `json:"details,omitempty"`
+	// MinFillRates maps a tracked field to the share of cards on a page that
+	// must have it; below that the site is treated as degraded.
+	MinFillRates map[string]float64 `json:"min_fill_rates,omitempty"`
+	// Schedule is the cron expression of the portal's runs, defaultSchedule
+	// when empty. Every listing of the portal follows it.
+	Schedule string `json:"schedule,omitempty"`
+	// Incremental, set only for portals that list the newest listings first,
+	// lets runs stop once they reach listings already stored.
+	Incremental *IncrementalSpec `json:"incremental,omitempty"`
+	// Politeness tightens how the portal's hosts are fetched.
+	Politeness *PolitenessSpec `json:"politeness,omitempty"`
+	// Structured reads listings from JSON embedded in the page, preferred
+	// to the card selectors when a page has it.
+	Structured *StructuredSpec `json:"structured,omitempty"`
+
+	cron CronSchedule
+}
+
+// defaultSchedule scrapes a portal every other day.
+const defaultSchedule = "0 3 */2 * *"
+
+// ListingURLs holds the first page of a listing and the template used for
+// the following pages, with %d standing for the page number.
+type ListingURLs struct {
+	StartURL string `json:"start_url"`
+	PageURL  string `json:"page_url"`
+}
+
+// FieldSpec reads one value from a card and stores it with Transform.
+// Selectors are tried in order until one yields a non-empty value. By default
+// the text of the first match is used; Attr reads an attribute instead, Join
+// concatenates all matches, Contains picks the first match whose text
+// contains it and Label picks the row whose LabelSelector text equals it.
+// Contains and Label texts are stripped from the value.
+type FieldSpec struct {
+	Field         string   `json:"field"`
+	Selectors     []string `json:"selectors"`
+	Attr          string   `json:"attr,omitempty"`
+	Join          string   `json:"join,omitempty"`
+	Contains      string   `json:"contains,omitempty"`
+	Label         string   `json:"label,omitempty"`
+	LabelSelector string   `json:"label_selector,omitempty"`
+	ValueSelector string   `json:"value_selector,omitempty"`
+	Transform     string   `json:"transform,omitempty"`
+}
+
+// Pagination strategies. until_empty walks pages until one has no cards;
+// total_count additionally stops once the total announced by the site has
+// been read and page_count once the last page linked by the pagination has
+// been read. MaxPages caps all of them.
+const (
+	PaginationUntilEmpty = "until_empty"
+	PaginationTotalCount = "total_count"
+	PaginationPageCount  = "page_count"
+)
+
+// PaginationSpec also tells how a listing page reports the size of the
+// listing, whatever the strategy: TotalSelector is the results counter and
+// PagesSelector the links searched for pages of the listing's page_url.
+// MaxDrop is the share of listings that may disappear between two runs
+// before the site is suspected of being broken.
+type PaginationSpec struct {
+	Strategy      string  `json:"strategy"`
+	MaxPages      int     `json:"max_pages,omitempty"`
+	TotalSelector string  `json:"total_selector,omitempty"`
+	PagesSelector string  `json:"pages_selector,omitempty"`
+	MaxDrop       float64 `json:"max_drop,omitempty"`
+}
+
+// IncrementalSpec stops a run after StopAfterPages consecutive pages holding
+// only stored listings with unchanged prices. The first run after every
+// FullSweep slot walks every page instead, so price changes further down the
+// listing and delistings are still picked up.
+type IncrementalSpec struct {
+	StopAfterPages int    `json:"stop_after_pages,omitempty"`
+	FullSweep      string `json:"full_sweep,omitempty"`
+
+	fullSweep CronSchedule
+}
+
+// Incremental defaults: give up after 3 known pages, sweep every Sunday.
+const (
+	defaultStopAfterPages = 3
+	defaultFullSweep      = "0 3 * * 0"
+)
+
+// transformFields lists, for every transform, the fields it can fill.
+// Composite transforms such as location_4zida fill several estate fields
+// from one value.
+var transformFields = map[string][]string{
+	"text":                {"street", "full_location", "city", "district", "municipality"},
+	"numeric":             {"price", "price_per_sqm", "square_meter"},
+	"currency":            {"currency"},
+	"floor":               {"floor"},
+	"rooms":               {"quantity_room"},
+	"serbian_rooms":       {"quantity_room"},
+	"url":                 {"link"},
+	"who_created":         {"who_created"},
+	"location_list":       {"full_location"},
+	"location_4zida":      {"full_location"},
+	"location_nekretnine": {"full_location"},
+	"location_cityexpert": {"full_location"},
+	"details_4zida":       {"details"},
+}
+
+// Site is one scrape target: a portal together with one of its listings.
+type Site struct {
+	Name        string
+	Source      string
+	ListingType string
+	URLs        ListingURLs
+	Spec        *SiteSpec
+}
+
+// List fetches and parses one listing page, returning its cards and what the
+// page tells about the size of the listing.
+func (s Site) List(page int) ([]RealEstate, PageInfo, error) {
+	return s.ListContext(context.Background(), page)
+}
+
+// ListContext is List with a request that is aborted once ctx is cancelled.
+func (s Site) ListContext(ctx context.Context, page int) ([]RealEstate, PageInfo, error) {
+	return parseWebSiteData(ctx, s, page)
+}
+
+// LoadSiteSpecs reads every *.json spec from dir, or the built-in specs when
+// dir is empty. Specs are returned in file name order.
+func LoadSiteSpecs(dir string) ([]SiteSpec, error) {
+	var fsys fs.FS = embeddedSiteSpecs
+	pattern := "sites/*.json"
+	if dir != "" {
+		fsys = os.DirFS(dir)
+		pattern = "*.json"
+	}
+
+	files, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list site specs: %w", err)
+	}
+	sort.Strings(files)
+
+	var specs []SiteSpec
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read site spec %s: %w", file, err)
+		}
+
+		spec, err := parseSiteSpec(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid site spec %s: %w", file, err)
+		}
+		specs = append(specs, spec)
+	}
+
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no site specs found in %q", dir)
+	}
+	return specs, nil
+}
+
+func parseSiteSpec(data []byte) (SiteSpec, error) {
+	var spec SiteSpec
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&spec); err != nil {
+		return spec, err
+	}
+	return spec, spec.validate()
+}
+
+func (spec *SiteSpec) validate() error {
+	if spec.Name == "" || spec.Source == "" {
+		return errors.New("name and source are required")
+	}
+	if spec.CardSelector == "" {
+		return errors.New("card_selector is required")
+	}
+	if len(spec.Listings) == 0 {
+		return errors.New("at least one listing is required")
+	}
+	for listingType, urls := range spec.Listings {
+		if listingType != ListingSale && listingType != ListingRent {
+			return fmt.Errorf("unknown listing type %q", listingType)
+		}
+		if urls.StartURL == "" || strings.Count(urls.PageURL, "%d") != 1 {
+			return fmt.Errorf("listing %s needs start_url and a page_url with one %%d", listingType)
+		}
+	}
+
+	for i := range spec.Fields {
+		f := &spec.Fields[i]
+		if f.Transform == "" {
+			f.Transform = "text"
+		}
+		fields, ok := transformFields[f.Transform]
+		if !ok {
+			return fmt.Errorf("field %s: unknown transform %q", f.Field, f.Transform)
+		}
+		if !slices.Contains(fields, f.Field) {
+			return fmt.Errorf("field %s: transform %s cannot fill it", f.Field, f.Transform)
+		}
+		if len(f.Selectors) == 0 {
+			return fmt.Errorf("field %s: at least one selector is required", f.Field)
+		}
+		if f.Label != "" && f.LabelSelector == "" {
+			return fmt.Errorf("field %s: label needs label_selector", f.Field)
+		}
+	}
+
+	switch spec.Pagination.Strategy {
+	case "":
+		spec.Pagination.Strategy = PaginationUntilEmpty
+	case PaginationUntilEmpty, PaginationPageCount:
+	case PaginationTotalCount:
+		if spec.Pagination.TotalSelector == "" {
+			return errors.New("total_count pagination needs total_selector")
+		}
+	default:
+		return fmt.Errorf("unknown pagination strategy %q", spec.Pagination.Strategy)
+	}
+	if spec.Pagination.MaxPages < 0 {
+		return errors.New("max_pages must not be negative")
+	}
+	if spec.Pagination.PagesSelector == "" {
+		spec.Pagination.PagesSelector = defaultPagesSelector
+	}
+	if spec.Pagination.MaxDrop == 0 {
+		spec.Pagination.MaxDrop = defaultMaxDrop
+	}
+	if spec.Pagination.MaxDrop < 0 || spec.Pagination.MaxDrop > 1 {
+		return errors.New("max_drop must be between 0 and 1")
+	}
+
+	for field, min := range spec.MinFillRates {
+		if !slices.Contains(fillRateFields, field) {
+			return fmt.Errorf("min_fill_rates: %s is not a tracked field", field)
+		}
+		if min < 0 || min > 1 {
+			return fmt.Errorf("min_fill_rates: %s must be between 0 and 1", field)
+		}
+	}
+
+	if spec.Schedule == "" {
+		spec.Schedule = defaultSchedule
+	}
+	cron, err := ParseCron(spec.Schedule)
+	if err != nil {
+		return fmt.Errorf("schedule: %w", err)
+	}
+	spec.cron = cron
+
+	if inc := spec.Incremental; inc != nil {
+		if inc.StopAfterPages < 0 {
+			return errors.New("incremental: stop_after_pages must not be negative")
+		}
+		if inc.StopAfterPages == 0 {
+			inc.StopAfterPages = defaultStopAfterPages
+		}
+		if inc.FullSweep == "" {
+			inc.FullSweep = defaultFullSweep
+		}
+		fullSweep, err := ParseCron(inc.FullSweep)
+		if err != nil {
+			return fmt.Errorf("incremental: full_sweep: %w", err)
+		}
+		inc.fullSweep = fullSweep
+	}
+
+	if spec.Politeness != nil {
+		if err := spec.Politeness.parse(); err != nil {
+			return fmt.Errorf("politeness: %w", err)
+		}
+	}
+
+	if spec.Structured != nil {
+		if err := spec.Structured.validate(); err != nil {
+			return fmt.Errorf("structured: %w", err)
+		}
+	}
+	return nil
+}
+
+// Sites expands specs into scrape targets, sale listings first. Rent targets
+// are named "<name>/rent" so their metrics stay apart from sales.
+func Sites(specs []SiteSpec) []Site {
+	var sites []Site
+	for _, listingType := range []string{ListingSale, ListingRent} {
+		for i := range specs {
+			spec := &specs[i]
+			urls, ok := spec.Listings[listingType]
+			if !ok {
+				continue
+			}
+			name := spec.Name
+			if listingType != ListingSale {
+				name += "/" + listingType
+			}
+			sites = append(sites, Site{
+				Name:        name,
+				Source:      spec.Source,
+				ListingType: listingType,
+				URLs:        urls,
+				Spec:        spec,
+			})
+		}
+	}
+	return sites
+}
+
+// parsePage extracts the listing cards of one listing page together with what
+// the page tells about the size of the listing. Cards without a price are
+// returned too so fill rates see them. Listings the page embeds as
+// structured data take precedence over what the selectors read.
+func (s Site) parsePage(e *colly.HTMLElement) ([]RealEstate, PageInfo) {
+	var estates []RealEstate
+	keepRaw := rawHTMLEnabled()
+	e.ForEach(s.Spec.CardSelector, func(_ int, card *colly.HTMLElement) {
+		estate := s.Spec.parseCard(card)
+		if keepRaw {
+			if html, err := goquery.OuterHtml(card.DOM); err == nil {
+				estate.RawHTML = html
+			}
+		}
+		estates = append(estates, estate)
+	})
+
+	if s.Spec.Structured != nil {
+		items := s.Spec.Structured.parse(e, s.Source)
+		for i := range items {
+			s.Spec.derivePricePerSqm(&items[i])
+		}
+		if len(items) > 0 {
+			slog.Debug("using structured data", "site", s.Name, "items", len(items), "cards", len(estates))
+			estates = preferStructured(items, estates)
+		}
+	}
+	for i := range estates {
+		estates[i].ListingType = s.ListingType
+		estates[i].PageURL = e.Request.URL.String()
+	}
+
+	var info PageInfo
+	if sel := s.Spec.Pagination.TotalSelector; sel != "" {
+		e.ForEach(sel, func(_ int, el *colly.HTMLElement) {
+			if count := parseTotalCount(strings.TrimSpace(el.Text)); count > 0 {
+				info.TotalItems = count
+			}
+		})
+	}
+	info.TotalPages = lastLinkedPage(e, s.Spec.Pagination.PagesSelector, s.URLs.PageURL)
+	return estates, info
+}
+
+func (spec *SiteSpec) parseCard(e *colly.HTMLElement) RealEstate {
+	estate := RealEstate{
+		Source:      spec.Source,
+		ParsingDate: time.Now(),
+	}
+
+	for _, f := range spec.Fields {
+		if value := f.extract(e); value != "" {
+			applyField(f, value, e, &estate)
+		}
+	}
+
+	spec.derivePricePerSqm(&estate)
+	return estate
+}
+
+func (spec *SiteSpec) derivePricePerSqm(estate *RealEstate) {
+	if spec.DerivePricePerSqm && estate.PricePerSquareMeter == 0 && estate.SquareMeter > 0 {
+		estate.PricePerSquareMeter = estate.Price / estate.SquareMeter
+	}
+}
+
+func (f FieldSpec) extract(e *colly.HTMLElement) string {
+	for _, sel := range f.Selectors {
+		if value := strings.TrimSpace(f.extractOne(e, sel)); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func (f FieldSpec) extractOne(e *colly.HTMLElement, sel string) string {
+	switch {
+	case f.Label != "":
+		var value string
+		e.ForEach(sel, func(_ int, el *colly.HTMLElement) {
+			if value != "" || el.ChildText(f.LabelSelector) != f.Label {
+				return
+			}
+			if f.ValueSelector != "" {
+				value = el.ChildText(f.ValueSelector)
+			} else {
+				value = el.Text
+			}
+			value = strings.ReplaceAll(value, f.Label, "")
+		})
+		return value
+	case f.Contains != "":
+		var value string
+		e.ForEach(sel, func(_ int, el *colly.HTMLElement) {
+			if value == "" && strings.Contains(el.Text, f.Contains) {
+				value = strings.ReplaceAll(el.Text, f.Contains, "")
+			}
+		})
+		return value
+	case f.Join != "":
+		return strings.Join(e.ChildTexts(sel), f.Join)
+	case f.Attr != "":
+		return e.ChildAttr(sel, f.Attr)
+	default:
+		return e.ChildText(sel)
+	}
+}
+
+func applyField(f FieldSpec, value string, e *colly.HTMLElement, estate *RealEstate) {
+	switch f.Transform {
+	case "numeric":
+		n := parseNumeric(value)
+		switch f.Field {
+		case "price":
+			estate.Price = n
+		case "price_per_sqm":
+			estate.PricePerSquareMeter = n
+		case "square_meter":
+			estate.SquareMeter = n
+		}
+	case "currency":
+		estate.Currency = parseCurrency(value)
+	case "floor":
+		estate.Floor, estate.FloorTotal = parseFloor(value)
+	case "rooms":
+		if r, err := strconv.ParseFloat(value, 32); err == nil {
+			estate.QuantityRoom = float32(r)
+		}
+	case "serbian_rooms":
+		estate.QuantityRoom = parseSerbianRooms(value)
+	case "url":
+		estate.Link = e.Request.AbsoluteURL(value)
+	case "who_created":
+		estate.WhoCreated = parseWhoCreated(value)
+	case "location_list":
+		estate.FullLocation = value
+		parseLocationList(value, estate)
+	case "location_4zida":
+		estate.FullLocation = value
+		parseLocationParts4Zida(value, estate)
+	case "location_nekretnine":
+		estate.FullLocation = value
+		parseLocationPartsNekretnine(value, estate)
+	case "location_cityexpert":
+		estate.FullLocation = value
+		parseLocationPartsCE(value, estate)
+	case "details_4zida":
+		parseDetails(value, estate)
+	default:
+		switch f.Field {
+		case "street":
+			estate.Street = value
+		case "full_location":
+			estate.FullLocation = value
+		case "city":
+			estate.City = value
+		case "district":
+			estate.District = value
+		case "municipality":
+			estate.Municipality = value
+		}
+	}
+}
+
+func parseWhoCreated(s string) WhoCreated {
+	s = strings.ToUpper(s)
+	switch {
+	case strings.Contains(s, "AGENCIJA"):
+		return Agent
+	case strings.Contains(s, "INVESTITOR"):
+		return Investor
+	case strings.Contains(s, "VLASNIK"):
+		return User
+	}
+	return Unknown
+}
+
+// parseLocationList reads "City, Municipality, District, Street" as listed
+// by halooglasi.com, each part being optional from the end.
+func parseLocationList(location string, estate *RealEstate) {
+	parts := strings.Split(location, ", ")
+	if len(parts) < 2 {
+		return
+	}
+	estate.City = parts[0]
+	estate.Municipality = parts[1]
+	if len(parts) >= 3 {
+		estate.District = parts[2]
+	}
+	if len(parts) >= 4 {
+		estate.Street = parts[3]
+	}
+}
+
+// siteByName finds a site by its metric name, e.g. "4zida.rs/rent".
+func siteByName(sites []Site, name string) (Site, bool) {
+	i := slices.IndexFunc(sites, func(s Site) bool { return s.Name == name })
+	if i < 0 {
+		return Site{}, false
+	}
+	return sites[i], true
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testSite returns the built-in sale target of a portal.
+func testSite(t *testing.T, name string) Site {
+	t.Helper()
+
+	specs, err := LoadSiteSpecs("")
+	if err != nil {
+		t.Fatalf("failed to load site specs: %v", err)
+	}
+	site, ok := siteByName(Sites(specs), name)
+	if !ok {
+		t.Fatalf("site %s is not defined", name)
+	}
+	return site
+}
+
+func testSiteWithURLs(t *testing.T, name string, startURL string, pageURL string) Site {
+	t.Helper()
+
+	site := testSite(t, name)
+	site.URLs = ListingURLs{StartURL: startURL, PageURL: pageURL}
+	return site
+}
+
+func TestLoadSiteSpecs(t *testing.T) {
+	specs, err := LoadSiteSpecs("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sites := Sites(specs)
+	want := []string{
+		"4zida.rs", "cityexpert.rs", "halooglasi.com", "nekretnine.rs",
+		"4zida.rs/rent", "cityexpert.rs/rent", "halooglasi.com/rent", "nekretnine.rs/rent",
+	}
+	if len(sites) != len(want) {
+		t.Fatalf("got %d sites; want %d", len(sites), len(want))
+	}
+	for i, name := range want {
+		if sites[i].Name != name {
+			t.Errorf("sites[%d] = %s; want %s", i, sites[i].Name, name)
+		}
+	}
+
+	fourZida, _ := siteByName(sites, "4zida.rs")
+	if p := fourZida.Spec.Pagination; p.Strategy != PaginationPageCount || p.MaxPages != 0 || p.PagesSelector != defaultPagesSelector {
+		t.Errorf("4zida.rs pagination = %+v; want page_count without a page limit", p)
+	}
+	rent, _ := siteByName(sites, "halooglasi.com/rent")
+	if rent.ListingType != ListingRent || !strings.Contains(rent.URLs.StartURL, "izdavanje") {
+		t.Errorf("unexpected rent target %+v", rent)
+	}
+}
+
+func TestLoadSiteSpecsFromDir(t *testing.T) {
+	dir := t.TempDir()
+	spec := `{
+		"name": "example.rs",
+		"source": "example.rs",
+		"listings": {"sale": {"start_url": "https://example.rs/stanovi", "page_url": "https://example.rs/stanovi?p=%d"}},
+		"card_selector": ".card",
+		"fields": [{"field": "price", "selectors": [".price"], "transform": "numeric"}]
+	}`
+	if err := os.WriteFile(filepath.Join(dir, "example.json"), []byte(spec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	specs, err := LoadSiteSpecs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 1 || specs[0].Name != "example.rs" {
+		t.Fatalf("unexpected specs %+v", specs)
+	}
+	if specs[0].Pagination.Strategy != PaginationUntilEmpty {
+		t.Errorf("default strategy = %q; want %q", specs[0].Pagination.Strategy, PaginationUntilEmpty)
+	}
+	if specs[0].Fields[0].Transform != "numeric" {
+		t.Errorf("transform = %q", specs[0].Fields[0].Transform)
+	}
+	if specs[0].Schedule != defaultSchedule || specs[0].cron.String() != defaultSchedule {
+		t.Errorf("default schedule = %q (%s); want %q", specs[0].Schedule, specs[0].cron, defaultSchedule)
+	}
+	if specs[0].Incremental != nil {
+		t.Errorf("incremental enabled without being declared: %+v", specs[0].Incremental)
+	}
+}
+
+func TestIncrementalSpecDefaults(t *testing.T) {
+	spec, err := parseSiteSpec([]byte(`{"name": "x", "source": "x", "card_selector": ".c",
+		"listings": {"sale": {"start_url": "https://x", "page_url": "https://x?p=%d"}},
+		"incremental": {}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	inc := spec.Incremental
+	if inc.StopAfterPages != defaultStopAfterPages || inc.FullSweep != defaultFullSweep || inc.fullSweep.String() != defaultFullSweep {
+		t.Errorf("incremental defaults = %+v", inc)
+	}
+}
+
+func TestSiteSpecValidation(t *testing.T) {
+	cases := map[string]string{
+		"unknown transform": `{"name": "x", "source": "x", "card_selector": ".c",
+			"listings": {"sale": {"start_url": "https://x", "page_url": "https://x?p=%d"}},
+			"fields": [{"field": "price", "selectors": [".p"], "transform": "magic"}]}`,
+		"transform cannot fill field": `{"name": "x", "source": "x", "card_selector": ".c",
+			"listings": {"sale": {"start_url": "https://x", "page_url": "https://x?p=%d"}},
+			"fields": [{"field": "price", "selectors": [".p"], "transform": "floor"}]}`,
+		"page url without placeholder": `{"name": "x", "source": "x", "card_selector": ".c",
+			"listings": {"sale": {"start_url": "https://x", "page_url": "https://x"}}}`,
+		"unknown listing type": `{"name": "x", "source": "x", "card_selector": ".c",
+			"listings": {"auction": {"start_url": "https://x", "page_url": "https://x?p=%d"}}}`,
+		"total count without selector": `{"name": "x", "source": "x", "card_selector": ".c",
+			"listings": {"sale": {"start_url": "https://x", "page_url": "https://x?p=%d"}},
+			"pagination": {"strategy": "total_count"}}`,
+		"invalid schedule": `{"name": "x", "source": "x", "card_selector": ".c",
+			"listings": {"sale": {"start_url": "https://x", "page_url": "https://x?p=%d"}},
+			"schedule": "every day"}`,
+		"invalid full sweep": `{"name": "x", "source": "x", "card_selector": ".c",
+			"listings": {"sale": {"start_url": "https://x", "page_url": "https://x?p=%d"}},
+			"incremental": {"full_sweep": "weekly"}}`,
+		"negative stop pages": `{"name": "x", "source": "x", "card_selector": ".c",
+			"listings": {"sale": {"start_url": "https://x", "page_url": "https://x?p=%d"}},
+			"incremental": {"stop_after_pages": -1}}`,
+		"unknown key": `{"name": "x", "source": "x", "card_selector": ".c", "cards": ".c",
+			"listings": {"sale": {"start_url": "https://x", "page_url": "https://x?p=%d"}}}`,
+	}
+
+	for name, spec := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseSiteSpec([]byte(spec)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	cases := []struct {
+		site     string
+		fixture  string
+		pageURL  string
+		total    int
+		pages    int
+		expected []RealEstate
+	}{
+		{
+			site:    "4zida.rs",
+			fixture: "4zida_list.html",
+			pageURL: "https://www.4zida.rs/prodaja-stanova/beograd",
+			pages:   42,
+			expected: []RealEstate{
+				{
+					Price: 150000, Currency: "EUR", PricePerSquareMeter: 2500, SquareMeter: 60,
+					City: "Beograd", District: "Crveni krst", Municipality: "Vračar", Street: "Njegoševa 10",
+					FullLocation: "Crveni krst, Vračar, Beograd", WhoCreated: Agent, QuantityRoom: 2.5, Floor: 3, FloorTotal: 5,
+					Link: "https://www.4zida.rs/prodaja-stanova/vracar-crveni-krst-beograd/dvoiposoban-stan/6611aa",
+				},
+				{
+					Price: 52000, Currency: "EUR", PricePerSquareMeter: 1733, SquareMeter: 30,
+					City: "Beograd", Municipality: "Zvezdara", Street: "Bulevar kralja Aleksandra 300",
+					FullLocation: "Zvezdara, Beograd", WhoCreated: User, QuantityRoom: 1, Floor: 1, FloorTotal: 4,
+					Link: "https://www.4zida.rs/prodaja-stanova/zvezdara-beograd/garsonjera/6611bb",
+				},
+			},
+		},
+		{
+			site:    "halooglasi.com",
+			fixture: "halooglasi_list.html",
+			pageURL: "https://www.halooglasi.com/nekretnine/prodaja-stanova/beograd",
+			expected: []RealEstate{
+				{
+					Price: 120000, Currency: "EUR", PricePerSquareMeter: 2000, SquareMeter: 60,
+					City: "Beograd", Municipality: "Opština Zvezdara", District: "Cvetkova pijaca", Street: "Živka Davidovića",
+					FullLocation: "Beograd, Opština Zvezdara, Cvetkova pijaca, Živka Davidovića", WhoCreated: Agent,
+					QuantityRoom: 2.5, Floor: 3, FloorTotal: 5,
+					Link: "https://www.halooglasi.com/nekretnine/prodaja-stanova/zvezdara-cvetkova-pijaca/5425645",
+				},
+				{
+					Price: 85000, Currency: "EUR", PricePerSquareMeter: 2125, SquareMeter: 40,
+					City: "Beograd", Municipality: "Opština Novi Beograd",
+					FullLocation: "Beograd, Opština Novi Beograd", WhoCreated: User, QuantityRoom: 1,
+					Link: "https://www.halooglasi.com/nekretnine/prodaja-stanova/novi-beograd-blok-45/5425646",
+				},
+			},
+		},
+		{
+			site:    "nekretnine.rs",
+			fixture: "nekretnine_list.html",
+			pageURL: "https://www.nekretnine.rs/stambeni-objekti/stanovi/izdavanje-prodaja/prodaja/grad/beograd/lista/",
+			pages:   187,
+			expected: []RealEstate{
+				{
+					Price: 95000, Currency: "EUR", PricePerSquareMeter: 1900, SquareMeter: 50,
+					City: "Beograd", District: "Zvezdara", FullLocation: "Zvezdara, Beograd", WhoCreated: User, QuantityRoom: 2,
+					Link: "https://www.nekretnine.rs/stambeni-objekti/stanovi/zvezdara-dvosoban-stan/NkWx1/",
+				},
+				{
+					Price: 70000, Currency: "EUR", PricePerSquareMeter: 2800, SquareMeter: 25,
+					City: "Beograd", District: "Vračar", FullLocation: "Vračar, Beograd", WhoCreated: Agent, QuantityRoom: 0.5,
+					Link: "https://www.nekretnine.rs/stambeni-objekti/stanovi/vracar-garsonjera/NkWx2/",
+				},
+			},
+		},
+		{
+			site:    "cityexpert.rs",
+			fixture: "cityexpert_list.html",
+			pageURL: "https://cityexpert.rs/prodaja-nekretnina/beograd?ptId=1",
+			total:   596,
+			expected: []RealEstate{
+				{
+					Price: 200000, Currency: "EUR", PricePerSquareMeter: 2500, SquareMeter: 80,
+					City: "Beograd", Municipality: "Vračar", Street: "Krunska", FullLocation: "Krunska, Vračar", QuantityRoom: 2,
+					Link: "https://cityexpert.rs/prodaja/stan/43211/dvosoban-stan-krunska-vracar",
+				},
+				{
+					Price: 180000, Currency: "EUR", PricePerSquareMeter: 2000, SquareMeter: 90,
+					City: "Beograd", Municipality: "Novi Beograd", Street: "Partizanske avijacije",
+					FullLocation: "Partizanske avijacije, Novi Beograd", QuantityRoom: 3,
+					Link: "https://cityexpert.rs/prodaja/stan/43212/trosoban-stan-bezanijska-kosa",
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.site, func(t *testing.T) {
+			site := testSite(t, c.site)
+			got, info := site.parsePage(loadFixture(t, c.fixture, c.pageURL))
+
+			if info.TotalItems != c.total || info.TotalPages != c.pages {
+				t.Errorf("page info = %+v; want %d items, %d pages", info, c.total, c.pages)
+			}
+			if len(got) != len(c.expected) {
+				t.Fatalf("got %d estates; want %d", len(got), len(c.expected))
+			}
+			for i, want := range c.expected {
+				want.Source = site.Source
+				want.ListingType = ListingSale
+				want.PageURL = c.pageURL
+				got[i].ParsingDate = want.ParsingDate
+				if got[i] != want {
+					t.Errorf("estate %d:\n got %+v\nwant %+v", i, got[i], want)
+				}
+			}
+		})
+	}
+}
@@ -1,16 +1,20 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type Storage struct {
-	db *sql.DB
+	db    *sql.DB
+	rates *ExchangeRates
 }
 
 func NewStorage(connStr string) (*Storage, error) {
@@ -26,10 +30,103 @@ func NewStorage(connStr string) (*Storage, error) {
 	return &Storage{db: db}, nil
 }
 
-func (s *Storage) Migrate() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS estates (
-		id SERIAL PRIMARY KEY,
+// SaveOutcome is what saving did to a listing. The values are used as the
+// status label of parser_items_processed_total.
+type SaveOutcome string
+
+const (
+	SaveInserted  SaveOutcome = "inserted"
+	SaveUpdated   SaveOutcome = "updated"
+	SaveUnchanged SaveOutcome = "unchanged"
+	SaveFailed    SaveOutcome = "failed"
+)
+
+// storedPrice is the price of a listing as it was before a save.
+type storedPrice struct {
+	price       int64
+	currency    string
+	pricePerSqm int64
+}
+
+// saveOutcome classifies a listing by comparing it with its stored price;
+// prev is nil for a listing that was not stored yet.
+func saveOutcome(prev *storedPrice, e RealEstate) SaveOutcome {
+	switch {
+	case prev == nil:
+		return SaveInserted
+	case prev.price != int64(e.Price) || prev.currency != e.Currency || prev.pricePerSqm != int64(e.PricePerSquareMeter):
+		return SaveUpdated
+	}
+	return SaveUnchanged
+}
+
+// priceChanged reports whether the save must append to estate_price_history.
+func priceChanged(prev *storedPrice, e RealEstate) bool {
+	return prev == nil || prev.price != int64(e.Price) || prev.currency != e.Currency
+}
+
+var stagingColumns = []string{
+	"price", "currency", "price_per_sqm", "square_meter", "city", "district", "municipality", "street",
+	"full_location", "who_created", "quantity_room", "floor", "floor_total", "link", "source", "attributes",
+	"listing_type", "price_eur", "price_per_sqm_eur",
+}
+
+// SaveEstate saves a single listing; see SaveEstates.
+func (s *Storage) SaveEstate(e RealEstate) error {
+	outcomes, err := s.SaveEstates(context.Background(), []RealEstate{e})
+	if err != nil {
+		return err
+	}
+	if outcomes[0] == SaveFailed {
+		return fmt.Errorf("failed to save estate %s", e.Link)
+	}
+	return nil
+}
+
+// SaveEstates writes a page of listings in one transaction: the rows are
+// copied into a staging table, upserted into estates with a single statement,
+// and estate_price_history gets a row for every listing that is new or whose
+// price changed. The returned outcomes line up with estates. A listing that
+// cannot be encoded fails on its own; a database error fails the whole page
+// and nothing is written.
+func (s *Storage) SaveEstates(ctx context.Context, estates []RealEstate) ([]SaveOutcome, error) {
+	outcomes := make([]SaveOutcome, len(estates))
+	if len(estates) == 0 {
+		return outcomes, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return failAll(outcomes, fmt.Errorf("failed to begin transaction: %w", err))
+	}
+	defer tx.Rollback()
+
+	outcomes, err = s.saveEstatesTx(ctx, tx, estates, time.Now(), true)
+	if err != nil {
+		return outcomes, err
+	}
+	if err := tx.Commit(); err != nil {
+		return failAll(outcomes, fmt.Errorf("failed to commit estates: %w", err))
+	}
+	return outcomes, nil
+}
+
+func failAll(outcomes []SaveOutcome, err error) ([]SaveOutcome, error) {
+	for i := range outcomes {
+		outcomes[i] = SaveFailed
+	}
+	return outcomes, err
+}
+
+// saveEstatesTx does the work of SaveEstates within tx, as if the listings
+// were seen at seenAt. A sighting marks the listings active again; saving
+// older data, such as a released quarantined estate, leaves the active state
+// and a later last_seen_at of stored listings alone.
+func (s *Storage) saveEstatesTx(ctx context.Context, tx *sql.Tx, estates []RealEstate, seenAt time.Time, sighting bool) ([]SaveOutcome, error) {
+	outcomes := make([]SaveOutcome, len(estates))
+
+	stagingQuery := `
+	CREATE TEMP TABLE estates_staging (
 		price INTEGER,
 		currency TEXT,
 		price_per_sqm INTEGER,
@@ -43,42 +140,784 @@ func (s *Storage) Migrate() error {
 		quantity_room REAL,
 		floor REAL,
 		floor_total REAL,
-		link TEXT UNIQUE,
-		parsing_date TIMESTAMP,
-		source TEXT
-	);`
+		link TEXT PRIMARY KEY,
+		source TEXT,
+		attributes JSONB,
+		listing_type TEXT,
+		price_eur INTEGER,
+		price_per_sqm_eur INTEGER
+	) ON COMMIT DROP;
+	`
+
+	prevQuery := `
+	SELECT e.link, COALESCE(e.price, 0), COALESCE(e.currency, ''), COALESCE(e.price_per_sqm, 0)
+	FROM estates e JOIN estates_staging s ON s.link = e.link
+	FOR UPDATE OF e;
+	`
+
+	upsertQuery := `
+	INSERT INTO estates (
+		price, currency, price_per_sqm, square_meter, city, district, municipality, street,
+		full_location, who_created, quantity_room, floor, floor_total, link, parsing_date, source, attributes,
+		listing_type, price_eur, price_per_sqm_eur, first_seen_at, last_seen_at, active
+	)
+	SELECT
+		price, currency, price_per_sqm, square_meter, city, district, municipality, street,
+		full_location, who_created, quantity_room, floor, floor_total, link, $1, source, attributes,
+		listing_type, price_eur, price_per_sqm_eur, $1, $1, TRUE
+	FROM estates_staging
+	ON CONFLICT (link) DO UPDATE SET
+		price = EXCLUDED.price,
+		currency = EXCLUDED.currency,
+		parsing_date = EXCLUDED.parsing_date,
+		price_per_sqm = EXCLUDED.price_per_sqm,
+		price_eur = EXCLUDED.price_eur,
+		price_per_sqm_eur = EXCLUDED.price_per_sqm_eur,
+		attributes = COALESCE(EXCLUDED.attributes, estates.attributes),
+		last_seen_at = GREATEST(estates.last_seen_at, EXCLUDED.last_seen_at),
+		active = $2 OR estates.active,
+		delisted_at = CASE WHEN $2 THEN NULL ELSE estates.delisted_at END;
+	`
+
+	historyQuery := `
+	INSERT INTO estate_price_history (link, price, currency, price_per_sqm, observed_at)
+	SELECT link, price, currency, price_per_sqm, $1
+	FROM estates_staging
+	WHERE link = ANY($2);
+	`
+
+	fail := func(err error) ([]SaveOutcome, error) {
+		return failAll(outcomes, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, stagingQuery); err != nil {
+		return fail(fmt.Errorf("failed to create staging table: %w", err))
+	}
+
+	copyStmt, err := tx.PrepareContext(ctx, pq.CopyIn("estates_staging", stagingColumns...))
+	if err != nil {
+		return fail(fmt.Errorf("failed to start copy: %w", err))
+	}
+
+	// A page can list the same link twice; the copy keeps the first one and
+	// the repeat reports the same outcome.
+	staged := make(map[string]int, len(estates))
+	for i, e := range estates {
+		if _, ok := staged[e.Link]; ok {
+			continue
+		}
+
+		attributes, err := marshalAttributes(e.Attributes)
+		if err != nil {
+			slog.Error("failed to encode attributes", "link", e.Link, "error", err)
+			outcomes[i] = SaveFailed
+			continue
+		}
+
+		_, err = copyStmt.ExecContext(ctx,
+			e.Price, e.Currency, e.PricePerSquareMeter, e.SquareMeter, e.City, e.District, e.Municipality, e.Street,
+			e.FullLocation, e.WhoCreated, e.QuantityRoom, e.Floor, e.FloorTotal, e.Link, e.Source, attributes,
+			listingTypeOrSale(e.ListingType),
+			s.rates.ToEUR(e.Price, e.Currency, seenAt), s.rates.ToEUR(e.PricePerSquareMeter, e.Currency, seenAt),
+		)
+		if err != nil {
+			copyStmt.Close()
+			return fail(fmt.Errorf("failed to stage estate %s: %w", e.Link, err))
+		}
+		staged[e.Link] = i
+	}
+	if _, err := copyStmt.ExecContext(ctx); err != nil {
+		copyStmt.Close()
+		return fail(fmt.Errorf("failed to copy estates: %w", err))
+	}
+	if err := copyStmt.Close(); err != nil {
+		return fail(fmt.Errorf("failed to finish copy: %w", err))
+	}
+
+	prev := make(map[string]*storedPrice, len(staged))
+	rows, err := tx.QueryContext(ctx, prevQuery)
+	if err != nil {
+		return fail(fmt.Errorf("failed to read previous prices: %w", err))
+	}
+	for rows.Next() {
+		var link string
+		var p storedPrice
+		if err := rows.Scan(&link, &p.price, &p.currency, &p.pricePerSqm); err != nil {
+			rows.Close()
+			return fail(fmt.Errorf("failed to scan previous price: %w", err))
+		}
+		prev[link] = &p
+	}
+	if err := rows.Close(); err != nil {
+		return fail(fmt.Errorf("failed to read previous prices: %w", err))
+	}
+	if err := rows.Err(); err != nil {
+		return fail(fmt.Errorf("failed to read previous prices: %w", err))
+	}
+
+	if _, err := tx.ExecContext(ctx, upsertQuery, seenAt, sighting); err != nil {
+		return fail(fmt.Errorf("failed to save estates: %w", err))
+	}
+
+	var changed []string
+	for link, i := range staged {
+		if priceChanged(prev[link], estates[i]) {
+			changed = append(changed, link)
+		}
+	}
+	if len(changed) > 0 {
+		if _, err := tx.ExecContext(ctx, historyQuery, seenAt, pq.Array(changed)); err != nil {
+			return fail(fmt.Errorf("failed to save price history: %w", err))
+		}
+	}
+
+	for i, e := range estates {
+		if outcomes[i] == SaveFailed {
+			continue
+		}
+		first := staged[e.Link]
+		outcomes[i] = saveOutcome(prev[e.Link], estates[first])
+	}
+	slog.Debug("estates saved", "count", len(staged), "price_changed", len(changed))
+	return outcomes, nil
+}
+
+// MarkDelisted deactivates listings of a source and listing type that were
+// not seen since runStart. It must only be called after a complete run.
+func (s *Storage) MarkDelisted(ctx context.Context, source string, listingType string, runStart time.Time) (int64, error) {
+	query := `
+	UPDATE estates SET active = FALSE, delisted_at = $4
+	WHERE source = $1 AND listing_type = $2 AND active AND last_seen_at < $3;
+	`
 
-	_, err := s.db.Exec(query)
+	res, err := s.db.ExecContext(ctx, query, source, listingTypeOrSale(listingType), runStart, time.Now())
 	if err != nil {
-		return fmt.Errorf("failed to run migration: %w", err)
+		slog.Error("failed to mark delisted estates", "source", source, "listing_type", listingType, "error", err)
+		return 0, fmt.Errorf("failed to mark delisted estates: %w", err)
 	}
-	slog.Info("Database migration completed successfully")
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count delisted estates: %w", err)
+	}
+	slog.Info("delisted estates marked", "source", source, "listing_type", listingType, "count", count)
+	return count, nil
+}
+
+func (s *Storage) LoadDedupCandidates(ctx context.Context) ([]dedupCandidate, error) {
+	query := `
+	SELECT id, COALESCE(cluster_id, 0), COALESCE(source, ''), listing_type, price, COALESCE(currency, ''), square_meter,
+		COALESCE(quantity_room, 0), COALESCE(floor, -5), COALESCE(floor_total, -5),
+		CONCAT_WS(' ', district, municipality, full_location)
+	FROM estates
+	WHERE active AND price > 0 AND square_meter > 0
+	ORDER BY id;
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dedup candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []dedupCandidate
+	for rows.Next() {
+		var c dedupCandidate
+		if err := rows.Scan(&c.ID, &c.ClusterID, &c.Source, &c.ListingType, &c.Price, &c.Currency, &c.SquareMeter,
+			&c.QuantityRoom, &c.Floor, &c.FloorTotal, &c.Location); err != nil {
+			return nil, fmt.Errorf("failed to scan dedup candidate: %w", err)
+		}
+		candidates = append(candidates, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return candidates, nil
+}
+
+// SaveClusters stores the cluster id of every listing in clusters
+// (estate id -> cluster id) with a single statement.
+func (s *Storage) SaveClusters(ctx context.Context, clusters map[int64]int64) error {
+	if len(clusters) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(clusters))
+	clusterIDs := make([]int64, 0, len(clusters))
+	for id, clusterID := range clusters {
+		ids = append(ids, id)
+		clusterIDs = append(clusterIDs, clusterID)
+	}
+
+	query := `
+	UPDATE estates e SET cluster_id = v.cluster_id
+	FROM (SELECT UNNEST($1::int[]) AS id, UNNEST($2::int[]) AS cluster_id) v
+	WHERE e.id = v.id;
+	`
+
+	if _, err := s.db.ExecContext(ctx, query, pq.Array(ids), pq.Array(clusterIDs)); err != nil {
+		return fmt.Errorf("failed to save clusters: %w", err)
+	}
+	slog.Debug("clusters saved", "count", len(clusters))
 	return nil
 }
 
-func (s *Storage) SaveEstate(e RealEstate) error {
+func listingTypeOrSale(listingType string) string {
+	if listingType == "" {
+		return ListingSale
+	}
+	return listingType
+}
+
+// marshalAttributes returns nil for empty attributes so list-only runs keep
+// the values collected by an earlier detail pass.
+func marshalAttributes(a EstateAttributes) (sql.NullString, error) {
+	if a.IsEmpty() {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// SaveRawHTML stores the gzip-compressed card HTML of a listing for the given
+// run, so it can be parsed again later with fixed selectors.
+func (s *Storage) SaveRawHTML(ctx context.Context, runID string, e RealEstate) error {
+	html, err := compressHTML(e.RawHTML)
+	if err != nil {
+		return fmt.Errorf("failed to compress html of %s: %w", e.Link, err)
+	}
+
 	query := `
-	INSERT INTO estates (
-		price, currency, price_per_sqm, square_meter, city, district, municipality, street, 
-		full_location, who_created, quantity_room, floor, floor_total, link, parsing_date, source
-	) VALUES (
-		$1, $2, $3, $4, $5, $6, $7, $8, 
-		$9, $10, $11, $12, $13, $14, $15, $16
-	) ON CONFLICT (link) DO UPDATE SET
-		price = EXCLUDED.price,
-		parsing_date = EXCLUDED.parsing_date,
-		price_per_sqm = EXCLUDED.price_per_sqm;
+	INSERT INTO estate_raw_html (link, run_id, source, listing_type, page_url, html, fetched_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (link, run_id) DO UPDATE SET
+		page_url = EXCLUDED.page_url,
+		html = EXCLUDED.html,
+		fetched_at = EXCLUDED.fetched_at;
 	`
 
-	_, err := s.db.Exec(query,
-		e.Price, e.Currency, e.PricePerSquareMeter, e.SquareMeter, e.City, e.District, e.Municipality, e.Street,
-		e.FullLocation, e.WhoCreated, e.QuantityRoom, e.Floor, e.FloorTotal, e.Link, time.Now(), e.Source,
-	)
+	if _, err := s.db.ExecContext(ctx, query, e.Link, runID, e.Source, listingTypeOrSale(e.ListingType), e.PageURL, html, e.ParsingDate); err != nil {
+		return fmt.Errorf("failed to save raw html of %s: %w", e.Link, err)
+	}
+	return nil
+}
 
+// EachRawHTML calls fn with the most recent archived HTML of every listing,
+// or with the HTML of a single run when runID is set. An empty source
+// matches all sources.
+func (s *Storage) EachRawHTML(runID string, source string, fn func(RawHTMLRecord) error) error {
+	query := `
+	SELECT DISTINCT ON (link) link, run_id, source, listing_type, page_url, html
+	FROM estate_raw_html
+	WHERE ($1 = '' OR run_id = $1) AND ($2 = '' OR source = $2)
+	ORDER BY link, fetched_at DESC;
+	`
+
+	rows, err := s.db.Query(query, runID, source)
 	if err != nil {
-		slog.Error("failed to save estate", "link", e.Link, "error", err)
-		return fmt.Errorf("failed to save estate: %w", err)
+		return fmt.Errorf("failed to query raw html: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r RawHTMLRecord
+		if err := rows.Scan(&r.Link, &r.RunID, &r.Source, &r.ListingType, &r.PageURL, &r.HTML); err != nil {
+			return fmt.Errorf("failed to scan raw html: %w", err)
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows error: %w", err)
 	}
-	slog.Debug("estate saved successfully", "link", e.Link, "source", e.Source)
 	return nil
 }
+
+// GetEstate returns the card fields currently stored for a listing.
+func (s *Storage) GetEstate(link string) (RealEstate, error) {
+	query := `
+	SELECT COALESCE(price, 0), COALESCE(currency, ''), COALESCE(price_per_sqm, 0), COALESCE(square_meter, 0),
+		COALESCE(city, ''), COALESCE(district, ''), COALESCE(municipality, ''), COALESCE(street, ''),
+		COALESCE(full_location, ''), COALESCE(who_created, 0), COALESCE(quantity_room, 0),
+		COALESCE(floor, 0), COALESCE(floor_total, 0), link, COALESCE(source, ''), listing_type
+	FROM estates
+	WHERE link = $1;
+	`
+
+	var e RealEstate
+	err := s.db.QueryRow(query, link).Scan(&e.Price, &e.Currency, &e.PricePerSquareMeter, &e.SquareMeter,
+		&e.City, &e.District, &e.Municipality, &e.Street,
+		&e.FullLocation, &e.WhoCreated, &e.QuantityRoom,
+		&e.Floor, &e.FloorTotal, &e.Link, &e.Source, &e.ListingType)
+	if err != nil {
+		return e, fmt.Errorf("failed to load estate %s: %w", link, err)
+	}
+	return e, nil
+}
+
+// UpdateCardFields overwrites the fields parsed from a listing card. Price
+// history is left alone: a re-parse corrects how a price was read, it does
+// not observe a new one.
+func (s *Storage) UpdateCardFields(e RealEstate) error {
+	query := `
+	UPDATE estates SET
+		price = $2, currency = $3, price_per_sqm = $4, square_meter = $5,
+		city = $6, district = $7, municipality = $8, street = $9, full_location = $10,
+		who_created = $11, quantity_room = $12, floor = $13, floor_total = $14,
+		price_eur = $15, price_per_sqm_eur = $16
+	WHERE link = $1;
+	`
+
+	at := e.ParsingDate
+	if at.IsZero() {
+		at = time.Now()
+	}
+	_, err := s.db.Exec(query, e.Link,
+		e.Price, e.Currency, e.PricePerSquareMeter, e.SquareMeter,
+		e.City, e.District, e.Municipality, e.Street, e.FullLocation,
+		e.WhoCreated, e.QuantityRoom, e.Floor, e.FloorTotal,
+		s.rates.ToEUR(e.Price, e.Currency, at), s.rates.ToEUR(e.PricePerSquareMeter, e.Currency, at))
+	if err != nil {
+		return fmt.Errorf("failed to update estate %s: %w", e.Link, err)
+	}
+	return nil
+}
+
+// QuarantineEstate stores an estate that failed validation together with the
+// violated rules instead of saving it. A listing already in estates is still
+// marked as seen, so a bad card does not get it delisted.
+func (s *Storage) QuarantineEstate(ctx context.Context, e RealEstate, rules []string) error {
+	e.RawHTML = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode quarantined estate %s: %w", e.Link, err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO estates_quarantine (link, source, listing_type, rules, estate, quarantined_at)
+	VALUES (NULLIF($1, ''), $2, $3, $4, $5, $6)
+	ON CONFLICT (link) DO UPDATE SET
+		rules = EXCLUDED.rules,
+		estate = EXCLUDED.estate,
+		quarantined_at = EXCLUDED.quarantined_at;
+	`
+	if _, err := tx.ExecContext(ctx, query, e.Link, e.Source, listingTypeOrSale(e.ListingType), pq.Array(rules), string(data), e.ParsingDate); err != nil {
+		return fmt.Errorf("failed to quarantine estate %s: %w", e.Link, err)
+	}
+
+	if e.Link != "" {
+		touchQuery := `UPDATE estates SET last_seen_at = $2, active = TRUE, delisted_at = NULL WHERE link = $1`
+		if _, err := tx.ExecContext(ctx, touchQuery, e.Link, e.ParsingDate); err != nil {
+			return fmt.Errorf("failed to mark estate %s as seen: %w", e.Link, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListQuarantine returns quarantined estates, newest first. Empty filters
+// match everything; limit <= 0 means no limit.
+func (s *Storage) ListQuarantine(rule string, source string, limit int) ([]QuarantinedEstate, error) {
+	query := `
+	SELECT id, rules, estate, quarantined_at
+	FROM estates_quarantine
+	WHERE ($1 = '' OR $1 = ANY(rules)) AND ($2 = '' OR source = $2)
+	ORDER BY quarantined_at DESC, id DESC
+	LIMIT NULLIF($3, 0);
+	`
+
+	if limit < 0 {
+		limit = 0
+	}
+	rows, err := s.db.Query(query, rule, source, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quarantine: %w", err)
+	}
+	defer rows.Close()
+
+	var result []QuarantinedEstate
+	for rows.Next() {
+		var q QuarantinedEstate
+		var data []byte
+		if err := rows.Scan(&q.ID, pq.Array(&q.Rules), &data, &q.QuarantinedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan quarantined estate: %w", err)
+		}
+		if err := json.Unmarshal(data, &q.Estate); err != nil {
+			return nil, fmt.Errorf("failed to decode quarantined estate %d: %w", q.ID, err)
+		}
+		result = append(result, q)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return result, nil
+}
+
+// ReleaseQuarantined saves a quarantined estate as is and removes it from the
+// quarantine in one transaction. The estate counts as seen when it was
+// quarantined, so releasing an old estate neither reactivates a listing
+// delisted since nor moves its last sighting forward.
+func (s *Storage) ReleaseQuarantined(id int64) error {
+	var data []byte
+	var quarantinedAt time.Time
+	err := s.db.QueryRow(`SELECT estate, quarantined_at FROM estates_quarantine WHERE id = $1`, id).Scan(&data, &quarantinedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("quarantined estate %d not found", id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load quarantined estate %d: %w", id, err)
+	}
+
+	var e RealEstate
+	if err := json.Unmarshal(data, &e); err != nil {
+		return fmt.Errorf("failed to decode quarantined estate %d: %w", id, err)
+	}
+	if e.Link == "" {
+		return fmt.Errorf("quarantined estate %d has no link and cannot be saved", id)
+	}
+
+	ctx := context.Background()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	outcomes, err := s.saveEstatesTx(ctx, tx, []RealEstate{e}, quarantinedAt, false)
+	if err != nil {
+		return err
+	}
+	if outcomes[0] == SaveFailed {
+		return fmt.Errorf("failed to save estate %s", e.Link)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM estates_quarantine WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete quarantined estate %d: %w", id, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to release quarantined estate %d: %w", id, err)
+	}
+	slog.Info("quarantined estate released", "id", id, "link", e.Link)
+	return nil
+}
+
+// SaveExchangeRates upserts rates imported from EXCHANGE_RATES_FILE.
+func (s *Storage) SaveExchangeRates(rates []ExchangeRate) error {
+	query := `
+	INSERT INTO exchange_rates (date, currency, rate) VALUES ($1, $2, $3)
+	ON CONFLICT (currency, date) DO UPDATE SET rate = EXCLUDED.rate;
+	`
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, r := range rates {
+		if _, err := tx.Exec(query, r.Date, r.Currency, r.Rate); err != nil {
+			return fmt.Errorf("failed to save exchange rate %s %s: %w", r.Currency, r.Date.Format(time.DateOnly), err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit exchange rates: %w", err)
+	}
+	return nil
+}
+
+// LoadExchangeRates reads every known rate; SaveEstate converts prices with
+// them from then on.
+func (s *Storage) LoadExchangeRates() error {
+	rows, err := s.db.Query("SELECT date, currency, rate FROM exchange_rates")
+	if err != nil {
+		return fmt.Errorf("failed to query exchange rates: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []ExchangeRate
+	for rows.Next() {
+		var r ExchangeRate
+		if err := rows.Scan(&r.Date, &r.Currency, &r.Rate); err != nil {
+			return fmt.Errorf("failed to scan exchange rate: %w", err)
+		}
+		rates = append(rates, r)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read exchange rates: %w", err)
+	}
+
+	s.rates = NewExchangeRates(rates)
+	return nil
+}
+
+// BackfillEURPrices fills price_eur for rows saved before their rate was
+// known, using the latest rate on or before the day they were parsed.
+func (s *Storage) BackfillEURPrices() (int64, error) {
+	query := `
+	UPDATE estates e SET
+		price_eur = CASE WHEN e.currency = 'EUR' THEN e.price ELSE ROUND(e.price / r.rate) END,
+		price_per_sqm_eur = CASE WHEN e.currency = 'EUR' THEN e.price_per_sqm ELSE ROUND(e.price_per_sqm / r.rate) END
+	FROM (
+		SELECT e2.link, COALESCE(
+			(SELECT rate FROM exchange_rates x
+			 WHERE x.currency = e2.currency AND x.date <= COALESCE(e2.parsing_date, NOW())::date
+			 ORDER BY x.date DESC LIMIT 1),
+			CASE WHEN e2.currency = 'EUR' THEN 1 END) AS rate
+		FROM estates e2
+		WHERE e2.price_eur IS NULL AND e2.price > 0
+	) r
+	WHERE e.link = r.link AND r.rate IS NOT NULL;
+	`
+
+	res, err := s.db.Exec(query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to backfill eur prices: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count backfilled estates: %w", err)
+	}
+	return count, nil
+}
+
+// LoadCheckpoint returns the checkpoint of site, or nil when its last run
+// finished.
+func (s *Storage) LoadCheckpoint(ctx context.Context, site string) (*Checkpoint, error) {
+	query := `
+	SELECT site, run_id, run_started_at, last_page, items_so_far, total_items, save_failed, updated_at
+	FROM scrape_checkpoints WHERE site = $1;
+	`
+
+	var cp Checkpoint
+	err := s.db.QueryRowContext(ctx, query, site).Scan(
+		&cp.Site, &cp.RunID, &cp.RunStartedAt, &cp.LastPage, &cp.ItemsSoFar, &cp.TotalItems, &cp.SaveFailed, &cp.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint of %s: %w", site, err)
+	}
+	return &cp, nil
+}
+
+// SaveCheckpoint records that cp.LastPage of the site's current run is saved.
+func (s *Storage) SaveCheckpoint(ctx context.Context, cp Checkpoint) error {
+	query := `
+	INSERT INTO scrape_checkpoints (site, run_id, run_started_at, last_page, items_so_far, total_items, save_failed, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	ON CONFLICT (site) DO UPDATE SET
+		run_id = EXCLUDED.run_id,
+		run_started_at = EXCLUDED.run_started_at,
+		last_page = EXCLUDED.last_page,
+		items_so_far = EXCLUDED.items_so_far,
+		total_items = EXCLUDED.total_items,
+		save_failed = EXCLUDED.save_failed,
+		updated_at = EXCLUDED.updated_at;
+	`
+
+	_, err := s.db.ExecContext(ctx, query, cp.Site, cp.RunID, cp.RunStartedAt, cp.LastPage, cp.ItemsSoFar, cp.TotalItems, cp.SaveFailed, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint of %s: %w", cp.Site, err)
+	}
+	return nil
+}
+
+func (s *Storage) ListCheckpoints(ctx context.Context) ([]Checkpoint, error) {
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT site, run_id, run_started_at, last_page, items_so_far, total_items, save_failed, updated_at
+	FROM scrape_checkpoints ORDER BY site;
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query checkpoints: %w", err)
+	}
+	defer rows.Close()
+
+	var checkpoints []Checkpoint
+	for rows.Next() {
+		var cp Checkpoint
+		if err := rows.Scan(&cp.Site, &cp.RunID, &cp.RunStartedAt, &cp.LastPage, &cp.ItemsSoFar, &cp.TotalItems, &cp.SaveFailed, &cp.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan checkpoint: %w", err)
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, rows.Err()
+}
+
+// ClearCheckpoints removes the checkpoint of site, or every checkpoint when
+// site is empty, so the next run starts from page 1.
+func (s *Storage) ClearCheckpoints(ctx context.Context, site string) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM scrape_checkpoints WHERE $1 = '' OR site = $1", site)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear checkpoints: %w", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count cleared checkpoints: %w", err)
+	}
+	return count, nil
+}
+
+const siteRunColumns = `run_id, site, source, listing_type, started_at, finished_at, resumed, pages,
+	items_inserted, items_updated, items_unchanged, items_failed, items_quarantined, items_skipped,
+	errors, termination, mode, reported_items`
+
+// SaveSiteRun upserts the bookkeeping of a site run. It is written after
+// every page, so an interrupted run still shows how far it got.
+func (s *Storage) SaveSiteRun(ctx context.Context, r *SiteRun) error {
+	query := `
+	INSERT INTO scrape_runs (` + siteRunColumns + `)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+	ON CONFLICT (run_id, site) DO UPDATE SET
+		finished_at = EXCLUDED.finished_at,
+		resumed = EXCLUDED.resumed,
+		pages = EXCLUDED.pages,
+		items_inserted = EXCLUDED.items_inserted,
+		items_updated = EXCLUDED.items_updated,
+		items_unchanged = EXCLUDED.items_unchanged,
+		items_failed = EXCLUDED.items_failed,
+		items_quarantined = EXCLUDED.items_quarantined,
+		items_skipped = EXCLUDED.items_skipped,
+		errors = EXCLUDED.errors,
+		termination = EXCLUDED.termination,
+		mode = EXCLUDED.mode,
+		reported_items = EXCLUDED.reported_items;
+	`
+
+	errs, err := json.Marshal(r.Errors)
+	if err != nil {
+		return fmt.Errorf("failed to encode run errors: %w", err)
+	}
+	var termination sql.NullString
+	if r.Termination != "" {
+		termination = sql.NullString{String: r.Termination, Valid: true}
+	}
+
+	_, err = s.db.ExecContext(ctx, query, r.RunID, r.Site, r.Source, r.ListingType, r.StartedAt, r.FinishedAt, r.Resumed, r.Pages,
+		r.ItemsInserted, r.ItemsUpdated, r.ItemsUnchanged, r.ItemsFailed, r.ItemsQuarantined, r.ItemsSkipped,
+		string(errs), termination, r.Mode, r.ReportedItems)
+	if err != nil {
+		return fmt.Errorf("failed to save run %s of %s: %w", r.RunID, r.Site, err)
+	}
+	return nil
+}
+
+func scanSiteRun(row interface{ Scan(...any) error }) (SiteRun, error) {
+	var r SiteRun
+	var finishedAt sql.NullTime
+	var errs []byte
+	var termination sql.NullString
+	err := row.Scan(&r.RunID, &r.Site, &r.Source, &r.ListingType, &r.StartedAt, &finishedAt, &r.Resumed, &r.Pages,
+		&r.ItemsInserted, &r.ItemsUpdated, &r.ItemsUnchanged, &r.ItemsFailed, &r.ItemsQuarantined, &r.ItemsSkipped,
+		&errs, &termination, &r.Mode, &r.ReportedItems)
+	if err != nil {
+		return r, err
+	}
+
+	if finishedAt.Valid {
+		r.FinishedAt = &finishedAt.Time
+	}
+	r.Termination = termination.String
+	if err := json.Unmarshal(errs, &r.Errors); err != nil {
+		return r, fmt.Errorf("failed to decode run errors: %w", err)
+	}
+	return r, nil
+}
+
+// LoadSiteRun returns the bookkeeping of a site run, or nil if there is none.
+func (s *Storage) LoadSiteRun(ctx context.Context, runID string, site string) (*SiteRun, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+siteRunColumns+` FROM scrape_runs WHERE run_id = $1 AND site = $2`, runID, site)
+	r, err := scanSiteRun(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load run %s of %s: %w", runID, site, err)
+	}
+	return &r, nil
+}
+
+// ListSiteRuns returns the latest site runs, newest first, optionally of a
+// single site.
+func (s *Storage) ListSiteRuns(ctx context.Context, site string, limit int) ([]SiteRun, error) {
+	query := `
+	SELECT ` + siteRunColumns + `
+	FROM scrape_runs
+	WHERE $1 = '' OR site = $1
+	ORDER BY started_at DESC, site
+	LIMIT $2;
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, site, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []SiteRun
+	for rows.Next() {
+		r, err := scanSiteRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan run: %w", err)
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// LastFullSweep returns the start of the latest full run of a site that
+// walked every page it was meant to, or the zero time if there is none.
+func (s *Storage) LastFullSweep(ctx context.Context, site string) (time.Time, error) {
+	query := `
+	SELECT MAX(started_at)
+	FROM scrape_runs
+	WHERE site = $1 AND mode = $2 AND termination IN ($3, $4);
+	`
+
+	var last sql.NullTime
+	err := s.db.QueryRowContext(ctx, query, site, RunModeFull, TerminationCompleted, TerminationMaxPages).Scan(&last)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to load last full sweep of %s: %w", site, err)
+	}
+	return last.Time, nil
+}
+
+// PreviousReportedItems returns the listing size reported in the latest run
+// of a site other than runID, or 0 if no run reported one.
+func (s *Storage) PreviousReportedItems(ctx context.Context, site string, runID string) (int, error) {
+	query := `
+	SELECT reported_items
+	FROM scrape_runs
+	WHERE site = $1 AND run_id <> $2 AND reported_items > 0
+	ORDER BY started_at DESC
+	LIMIT 1;
+	`
+
+	var reported int
+	err := s.db.QueryRowContext(ctx, query, site, runID).Scan(&reported)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to load previous run size of %s: %w", site, err)
+	}
+	return reported, nil
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"log/slog"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -16,7 +18,7 @@ func TestStorageIntegration(t *testing.T) {
 	defer storage.db.Close()
 
 	// Clean up before test
-	_, err = storage.db.Exec("DROP TABLE IF EXISTS estates")
+	_, err = storage.db.Exec("DROP TABLE IF EXISTS estates, estate_price_history, schema_migrations")
 	if err != nil {
 		t.Fatalf("Failed to drop table: %v", err)
 	}
@@ -76,5 +78,152 @@ func TestStorageIntegration(t *testing.T) {
 		t.Errorf("Expected updated price 160000, got %d", newPrice)
 	}
 
+	// Saving the same price again must not add another history entry
+	err = storage.SaveEstate(testEstate)
+	if err != nil {
+		t.Fatalf("SaveEstate repeat failed: %v", err)
+	}
+
+	var historyCount int
+	err = storage.db.QueryRow("SELECT COUNT(*) FROM estate_price_history WHERE link = $1", testEstate.Link).Scan(&historyCount)
+	if err != nil {
+		t.Fatalf("Failed to query price history: %v", err)
+	}
+
+	if historyCount != 2 {
+		t.Errorf("Expected 2 price history records, got %d", historyCount)
+	}
+
+	second := testEstate
+	second.Link = "https://test.com/estate/2"
+	testEstate.Price = 155000
+	outcomes, err := storage.SaveEstates(context.Background(), []RealEstate{testEstate, second, second})
+	if err != nil {
+		t.Fatalf("SaveEstates failed: %v", err)
+	}
+	want := []SaveOutcome{SaveUpdated, SaveInserted, SaveInserted}
+	if !reflect.DeepEqual(outcomes, want) {
+		t.Errorf("Expected outcomes %v, got %v", want, outcomes)
+	}
+
+	outcomes, err = storage.SaveEstates(context.Background(), []RealEstate{testEstate, second})
+	if err != nil {
+		t.Fatalf("SaveEstates repeat failed: %v", err)
+	}
+	want = []SaveOutcome{SaveUnchanged, SaveUnchanged}
+	if !reflect.DeepEqual(outcomes, want) {
+		t.Errorf("Expected outcomes %v, got %v", want, outcomes)
+	}
+
+	// A listing switching from RSD to EUR gets one history row for the
+	// switch and none for the next save in EUR.
+	switched := testEstate
+	switched.Link = "https://test.com/estate/3"
+	switched.Price, switched.Currency = 17500000, "RSD"
+	if _, err := storage.SaveEstates(context.Background(), []RealEstate{switched}); err != nil {
+		t.Fatalf("SaveEstates in RSD failed: %v", err)
+	}
+	switched.Price, switched.Currency = 150000, "EUR"
+	var switchOutcomes []SaveOutcome
+	for range 2 {
+		outcomes, err := storage.SaveEstates(context.Background(), []RealEstate{switched})
+		if err != nil {
+			t.Fatalf("SaveEstates in EUR failed: %v", err)
+		}
+		switchOutcomes = append(switchOutcomes, outcomes...)
+	}
+	want = []SaveOutcome{SaveUpdated, SaveUnchanged}
+	if !reflect.DeepEqual(switchOutcomes, want) {
+		t.Errorf("Expected outcomes %v after the currency switch, got %v", want, switchOutcomes)
+	}
+
+	var currency string
+	err = storage.db.QueryRow("SELECT currency FROM estates WHERE link = $1", switched.Link).Scan(&currency)
+	if err != nil {
+		t.Fatalf("Failed to query currency: %v", err)
+	}
+	if currency != "EUR" {
+		t.Errorf("Expected currency EUR after the switch, got %s", currency)
+	}
+
+	err = storage.db.QueryRow("SELECT COUNT(*) FROM estate_price_history WHERE link = $1 AND currency = 'EUR'", switched.Link).Scan(&historyCount)
+	if err != nil {
+		t.Fatalf("Failed to query price history: %v", err)
+	}
+	if historyCount != 1 {
+		t.Errorf("Expected 1 price history record in EUR, got %d", historyCount)
+	}
+
+	// Releasing an estate quarantined before the listing was delisted
+	// neither reactivates it nor moves its last sighting back or forth.
+	if _, err := storage.db.Exec("DELETE FROM estates_quarantine"); err != nil {
+		t.Fatalf("Failed to clear quarantine: %v", err)
+	}
+	stale := testEstate
+	stale.ParsingDate = time.Now().Add(-30 * 24 * time.Hour)
+	if err := storage.QuarantineEstate(context.Background(), stale, []string{"price_range"}); err != nil {
+		t.Fatalf("QuarantineEstate failed: %v", err)
+	}
+	if _, err := storage.SaveEstates(context.Background(), []RealEstate{testEstate}); err != nil {
+		t.Fatalf("SaveEstates failed: %v", err)
+	}
+	if _, err := storage.MarkDelisted(context.Background(), "test", "", time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("MarkDelisted failed: %v", err)
+	}
+	var lastSeen time.Time
+	err = storage.db.QueryRow("SELECT last_seen_at FROM estates WHERE link = $1", testEstate.Link).Scan(&lastSeen)
+	if err != nil {
+		t.Fatalf("Failed to query last_seen_at: %v", err)
+	}
+
+	quarantined, err := storage.ListQuarantine("", "test", 0)
+	if err != nil || len(quarantined) != 1 {
+		t.Fatalf("ListQuarantine = %d estates, %v; want 1", len(quarantined), err)
+	}
+	if err := storage.ReleaseQuarantined(quarantined[0].ID); err != nil {
+		t.Fatalf("ReleaseQuarantined failed: %v", err)
+	}
+
+	var active bool
+	var releasedSeen time.Time
+	err = storage.db.QueryRow("SELECT active, last_seen_at FROM estates WHERE link = $1", testEstate.Link).Scan(&active, &releasedSeen)
+	if err != nil {
+		t.Fatalf("Failed to query released estate: %v", err)
+	}
+	if active {
+		t.Error("Expected the released estate to stay delisted")
+	}
+	if !releasedSeen.Equal(lastSeen) {
+		t.Errorf("Expected last_seen_at %v after the release, got %v", lastSeen, releasedSeen)
+	}
+	if quarantined, _ := storage.ListQuarantine("", "test", 0); len(quarantined) != 0 {
+		t.Errorf("Expected the quarantine to be empty, got %d estates", len(quarantined))
+	}
+
 	slog.Info("Integration test passed successfully")
 }
+
+func TestSaveOutcome(t *testing.T) {
+	estate := RealEstate{Price: 100000, Currency: "EUR", PricePerSquareMeter: 2000}
+	cases := []struct {
+		name        string
+		prev        *storedPrice
+		want        SaveOutcome
+		wantHistory bool
+	}{
+		{"new listing", nil, SaveInserted, true},
+		{"same price", &storedPrice{100000, "EUR", 2000}, SaveUnchanged, false},
+		{"price cut", &storedPrice{110000, "EUR", 2000}, SaveUpdated, true},
+		{"currency switch", &storedPrice{100000, "RSD", 2000}, SaveUpdated, true},
+		{"area corrected", &storedPrice{100000, "EUR", 2100}, SaveUpdated, false},
+	}
+
+	for _, c := range cases {
+		if got := saveOutcome(c.prev, estate); got != c.want {
+			t.Errorf("%s: outcome = %s; want %s", c.name, got, c.want)
+		}
+		if got := priceChanged(c.prev, estate); got != c.wantHistory {
+			t.Errorf("%s: priceChanged = %v; want %v", c.name, got, c.wantHistory)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// defaultStructuredScript selects the JSON-LD blocks of a page.
+const defaultStructuredScript = `script[type="application/ld+json"]`
+
+// StructuredSpec reads listings from data a page embeds as JSON, either
+// JSON-LD blocks or an inline script assigning a JavaScript variable. Such
+// data outlives redesigns of the markup, so it is preferred to the card
+// selectors whenever a page has it.
+//
+// Script selects the script elements, the JSON-LD blocks by default. With
+// Variable set, the JSON is the value the script assigns to it, as in
+// "window.__STATE__ = {...};". Items is the dot separated path from a block
+// to its listings and Type, if set, keeps only listings of that JSON-LD
+// @type. Paths go through arrays, so "itemListElement.item" reads the item
+// of every element of an ItemList.
+type StructuredSpec struct {
+	Script   string            `json:"script,omitempty"`
+	Variable string            `json:"variable,omitempty"`
+	Items    string            `json:"items,omitempty"`
+	Type     string            `json:"type,omitempty"`
+	Fields   []StructuredField `json:"fields"`
+}
+
+// StructuredField stores the value at Path of a listing with Transform.
+// Numbers, and numeric strings such as "95000.00", go straight into
+// numeric fields unless another transform is given.
+type StructuredField struct {
+	Field     string `json:"field"`
+	Path      string `json:"path"`
+	Transform string `json:"transform,omitempty"`
+}
+
+// structuredTransforms is the transform of a field when the spec names
+// none; fields not listed are stored as text.
+var structuredTransforms = map[string]string{
+	"price":         "numeric",
+	"price_per_sqm": "numeric",
+	"square_meter":  "numeric",
+	"currency":      "currency",
+	"floor":         "floor",
+	"quantity_room": "rooms",
+	"link":          "url",
+	"who_created":   "who_created",
+}
+
+func (spec *StructuredSpec) validate() error {
+	if spec.Script == "" {
+		spec.Script = defaultStructuredScript
+	}
+	if len(spec.Fields) == 0 {
+		return errors.New("at least one field is required")
+	}
+	hasLink := false
+	for i := range spec.Fields {
+		f := &spec.Fields[i]
+		if f.Path == "" {
+			return fmt.Errorf("field %s: path is required", f.Field)
+		}
+		if f.Transform == "" {
+			f.Transform = cmp.Or(structuredTransforms[f.Field], "text")
+		}
+		fields, ok := transformFields[f.Transform]
+		if !ok {
+			return fmt.Errorf("field %s: unknown transform %q", f.Field, f.Transform)
+		}
+		if !slices.Contains(fields, f.Field) {
+			return fmt.Errorf("field %s: transform %s cannot fill it", f.Field, f.Transform)
+		}
+		hasLink = hasLink || f.Field == "link"
+	}
+	// Listings are matched with their cards by link.
+	if !hasLink {
+		return errors.New("a link field is required")
+	}
+	return nil
+}
+
+// parse reads the listings embedded in a page, in the order the page lists
+// them. It returns nil when the page embeds none; scripts without the
+// variable and blocks that are not valid JSON are skipped.
+func (spec *StructuredSpec) parse(e *colly.HTMLElement, source string) []RealEstate {
+	var estates []RealEstate
+	e.ForEach(spec.Script, func(_ int, script *colly.HTMLElement) {
+		if spec.Variable != "" && !strings.Contains(script.Text, spec.Variable) {
+			return
+		}
+		data, err := decodeEmbeddedJSON(script.Text, spec.Variable)
+		if err != nil {
+			slog.Warn("skipping embedded data", "source", source, "url", e.Request.URL.String(), "error", err)
+			return
+		}
+		for _, root := range jsonRoots(data) {
+			for _, item := range lookupPath(root, spec.Items) {
+				obj, ok := item.(map[string]any)
+				if !ok || !hasJSONLDType(obj, spec.Type) {
+					continue
+				}
+				estate := RealEstate{Source: source, ParsingDate: time.Now()}
+				for _, f := range spec.Fields {
+					if values := lookupPath(obj, f.Path); len(values) > 0 {
+						f.apply(values[0], e, &estate)
+					}
+				}
+				estates = append(estates, estate)
+			}
+		}
+	})
+	return estates
+}
+
+// decodeEmbeddedJSON decodes the JSON of a script: all of it, or the value
+// assigned to variable. Whatever follows the value, such as a semicolon or
+// more statements, is ignored.
+func decodeEmbeddedJSON(text, variable string) (any, error) {
+	if variable != "" {
+		i := strings.Index(text, variable)
+		if i < 0 {
+			return nil, fmt.Errorf("%s is not assigned", variable)
+		}
+		rest, ok := strings.CutPrefix(strings.TrimSpace(text[i+len(variable):]), "=")
+		if !ok {
+			return nil, fmt.Errorf("%s is not assigned", variable)
+		}
+		text = rest
+	}
+
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	var data any
+	if err := dec.Decode(&data); err != nil {
+		return nil, fmt.Errorf("invalid embedded json: %w", err)
+	}
+	return data, nil
+}
+
+// jsonRoots splits a block into the objects it describes: the elements of a
+// top-level array and of a JSON-LD @graph.
+func jsonRoots(data any) []any {
+	var roots []any
+	for _, v := range appendFlat(nil, data) {
+		if obj, ok := v.(map[string]any); ok && obj["@graph"] != nil {
+			roots = appendFlat(roots, obj["@graph"])
+			continue
+		}
+		roots = append(roots, v)
+	}
+	return roots
+}
+
+// lookupPath returns every value at a dot separated path below v, going
+// through arrays. An empty path returns v itself.
+func lookupPath(v any, path string) []any {
+	values := appendFlat(nil, v)
+	if path == "" {
+		return values
+	}
+	for _, key := range strings.Split(path, ".") {
+		var next []any
+		for _, v := range values {
+			if obj, ok := v.(map[string]any); ok {
+				next = appendFlat(next, obj[key])
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+func appendFlat(values []any, v any) []any {
+	switch v := v.(type) {
+	case nil:
+		return values
+	case []any:
+		for _, el := range v {
+			values = appendFlat(values, el)
+		}
+		return values
+	}
+	return append(values, v)
+}
+
+// hasJSONLDType reports whether obj is of type want; @type may list several.
+// An empty want matches any object.
+func hasJSONLDType(obj map[string]any, want string) bool {
+	if want == "" {
+		return true
+	}
+	for _, t := range appendFlat(nil, obj["@type"]) {
+		if t == want {
+			return true
+		}
+	}
+	return false
+}
+
+func (f StructuredField) apply(value any, e *colly.HTMLElement, estate *RealEstate) {
+	var text string
+	switch v := value.(type) {
+	case json.Number:
+		text = v.String()
+	case string:
+		text = strings.TrimSpace(v)
+	default:
+		return
+	}
+	if text == "" {
+		return
+	}
+	if f.Transform == structuredTransforms[f.Field] && setNumber(f.Field, text, estate) {
+		return
+	}
+	applyField(FieldSpec{Field: f.Field, Transform: f.Transform}, text, e, estate)
+}
+
+// setNumber stores a machine-formatted number in a numeric field. The
+// transforms read numbers as the portals print them, with dots grouping
+// thousands, which would turn "95000.00" into 9500000.
+func setNumber(field, text string, estate *RealEstate) bool {
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return false
+	}
+	switch field {
+	case "price":
+		estate.Price = int32(n + 0.5)
+	case "price_per_sqm":
+		estate.PricePerSquareMeter = int32(n + 0.5)
+	case "square_meter":
+		estate.SquareMeter = int32(n + 0.5)
+	case "quantity_room":
+		estate.QuantityRoom = float32(n)
+	case "floor":
+		estate.Floor = float32(n)
+	default:
+		return false
+	}
+	return true
+}
+
+// preferStructured combines the listings a page embeds with its cards.
+// Every card keeps its place, its values replaced by those of the embedded
+// listing with the same link; what the embedded data lacks is still taken
+// from the card. Cards without a link cannot be matched and are dropped,
+// embedded listings without a card are added at the end.
+func preferStructured(items, cards []RealEstate) []RealEstate {
+	byLink := make(map[string]int, len(items))
+	for i, item := range items {
+		if _, dup := byLink[item.Link]; item.Link != "" && !dup {
+			byLink[item.Link] = i
+		}
+	}
+
+	used := make([]bool, len(items))
+	estates := make([]RealEstate, 0, max(len(items), len(cards)))
+	for _, card := range cards {
+		i, ok := byLink[card.Link]
+		if !ok || used[i] {
+			if card.Link != "" {
+				estates = append(estates, card)
+			}
+			continue
+		}
+		used[i] = true
+		estate := items[i]
+		fillMissing(&estate, card)
+		estates = append(estates, estate)
+	}
+	for i, item := range items {
+		if !used[i] {
+			estates = append(estates, item)
+		}
+	}
+	return estates
+}
+
+// fillMissing copies to dst the values of src that dst does not have.
+func fillMissing(dst *RealEstate, src RealEstate) {
+	if dst.Price == 0 {
+		dst.Price = src.Price
+	}
+	if dst.Currency == "" {
+		dst.Currency = src.Currency
+	}
+	if dst.PricePerSquareMeter == 0 {
+		dst.PricePerSquareMeter = src.PricePerSquareMeter
+	}
+	if dst.SquareMeter == 0 {
+		dst.SquareMeter = src.SquareMeter
+	}
+	if dst.City == "" {
+		dst.City = src.City
+	}
+	if dst.District == "" {
+		dst.District = src.District
+	}
+	if dst.Municipality == "" {
+		dst.Municipality = src.Municipality
+	}
+	if dst.Street == "" {
+		dst.Street = src.Street
+	}
+	if dst.FullLocation == "" {
+		dst.FullLocation = src.FullLocation
+	}
+	if dst.WhoCreated == Unknown {
+		dst.WhoCreated = src.WhoCreated
+	}
+	if dst.QuantityRoom == 0 {
+		dst.QuantityRoom = src.QuantityRoom
+	}
+	if dst.Floor == 0 {
+		dst.Floor = src.Floor
+	}
+	if dst.FloorTotal == 0 {
+		dst.FloorTotal = src.FloorTotal
+	}
+	if dst.RawHTML == "" {
+		dst.RawHTML = src.RawHTML
+	}
+}
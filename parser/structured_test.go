@@ -0,0 +1,187 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStructuredMatchesSelectors(t *testing.T) {
+	cases := []struct {
+		site, fixture, pageURL string
+	}{
+		{"halooglasi.com", "halooglasi_list.html", "https://www.halooglasi.com/nekretnine/prodaja-stanova/beograd"},
+		{"nekretnine.rs", "nekretnine_list.html", "https://www.nekretnine.rs/stambeni-objekti/stanovi/izdavanje-prodaja/prodaja/grad/beograd/lista/"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.site, func(t *testing.T) {
+			site := testSite(t, c.site)
+			page := loadFixture(t, c.fixture, c.pageURL)
+
+			items := site.Spec.Structured.parse(page, site.Source)
+			preferred, _ := site.parsePage(page)
+
+			selectorsOnly := *site.Spec
+			selectorsOnly.Structured = nil
+			site.Spec = &selectorsOnly
+			cards, _ := site.parsePage(page)
+
+			if len(items) != len(cards) || len(preferred) != len(cards) {
+				t.Fatalf("got %d embedded listings, %d preferred and %d cards; want the same", len(items), len(preferred), len(cards))
+			}
+			for i, card := range cards {
+				item := items[i]
+				if item.Link == "" || item.Link != card.Link || item.Price == 0 || item.Price != card.Price {
+					t.Errorf("listing %d: embedded data read link %q, price %d; cards %q, %d", i, item.Link, item.Price, card.Link, card.Price)
+				}
+
+				// Whatever the embedded data has agrees with the card.
+				fillMissing(&item, card)
+				item.ListingType, item.PageURL = card.ListingType, card.PageURL
+				item.ParsingDate, card.ParsingDate = time.Time{}, time.Time{}
+				if item != card {
+					t.Errorf("listing %d:\nembedded %+v\n   cards %+v", i, item, card)
+				}
+
+				preferred[i].ParsingDate = time.Time{}
+				if preferred[i] != card {
+					t.Errorf("listing %d:\npreferred %+v\n    cards %+v", i, preferred[i], card)
+				}
+			}
+		})
+	}
+}
+
+func TestStructuredFallback(t *testing.T) {
+	site := testSite(t, "halooglasi.com")
+	page := loadFixture(t, "halooglasi_list.html", "https://www.halooglasi.com/nekretnine/prodaja-stanova/beograd")
+	page.DOM.Find("script").Remove()
+
+	if items := site.Spec.Structured.parse(page, site.Source); items != nil {
+		t.Fatalf("got %d embedded listings from a page without any", len(items))
+	}
+	estates, _ := site.parsePage(page)
+	if len(estates) != 2 || estates[0].Price != 120000 || estates[1].Link == "" {
+		t.Errorf("selectors did not take over: %+v", estates)
+	}
+}
+
+func TestPreferStructured(t *testing.T) {
+	items := []RealEstate{
+		{Link: "https://a.test/2", Price: 200, Currency: "EUR"},
+		{Link: "https://a.test/1", Price: 100},
+		{Link: "https://a.test/4", Price: 400},
+	}
+	cards := []RealEstate{
+		{Link: "https://a.test/1", Price: 99, Currency: "EUR", WhoCreated: Agent, RawHTML: "<div>1</div>"},
+		{Price: 150},
+		{Link: "https://a.test/2", Price: 199, SquareMeter: 50},
+		{Link: "https://a.test/3", Price: 300},
+	}
+
+	got := preferStructured(items, cards)
+	want := []RealEstate{
+		{Link: "https://a.test/1", Price: 100, Currency: "EUR", WhoCreated: Agent, RawHTML: "<div>1</div>"},
+		{Link: "https://a.test/2", Price: 200, Currency: "EUR", SquareMeter: 50},
+		{Link: "https://a.test/3", Price: 300},
+		{Link: "https://a.test/4", Price: 400},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d estates; want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("estate %d:\n got %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestDecodeEmbeddedJSON(t *testing.T) {
+	data, err := decodeEmbeddedJSON(`window.dataLayer = [];
+		window.__STATE__ = {"list": {"ads": [{"url": "/1"}, {"url": "/2"}]}}; init(window.__STATE__);`, "window.__STATE__")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := lookupPath(data, "list.ads.url"); len(got) != 2 || got[0] != "/1" || got[1] != "/2" {
+		t.Errorf("list.ads.url = %v; want [/1 /2]", got)
+	}
+
+	for _, bad := range []struct{ text, variable string }{
+		{`{"ads": [`, ""},
+		{`window.other = {};`, "window.__STATE__"},
+		{`if (window.__STATE__) {}`, "window.__STATE__"},
+	} {
+		if _, err := decodeEmbeddedJSON(bad.text, bad.variable); err == nil {
+			t.Errorf("%q: expected an error", bad.text)
+		}
+	}
+}
+
+func TestJSONLDGraph(t *testing.T) {
+	data, err := decodeEmbeddedJSON(`{"@context": "https://schema.org", "@graph": [
+		{"@type": "Organization", "url": "https://a.test/"},
+		{"@type": "Apartment", "url": "https://a.test/1"},
+		{"@type": ["Apartment", "Product"], "url": "https://a.test/2"}
+	]}`, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var urls []any
+	for _, root := range jsonRoots(data) {
+		if obj, ok := root.(map[string]any); ok && hasJSONLDType(obj, "Apartment") {
+			urls = append(urls, obj["url"])
+		}
+	}
+	if len(urls) != 2 || urls[0] != "https://a.test/1" || urls[1] != "https://a.test/2" {
+		t.Errorf("apartments %v; want https://a.test/1 and https://a.test/2", urls)
+	}
+}
+
+func TestStructuredFieldApply(t *testing.T) {
+	page := loadFixture(t, "nekretnine_list.html", "https://www.nekretnine.rs/lista/")
+	cases := []struct {
+		field StructuredField
+		value any
+		want  RealEstate
+	}{
+		{StructuredField{Field: "price", Transform: "numeric"}, "95000.00", RealEstate{Price: 95000}},
+		{StructuredField{Field: "price", Transform: "numeric"}, "95.000 €", RealEstate{Price: 95000}},
+		{StructuredField{Field: "quantity_room", Transform: "rooms"}, "2.5", RealEstate{QuantityRoom: 2.5}},
+		{StructuredField{Field: "quantity_room", Transform: "serbian_rooms"}, "Dvosoban stan", RealEstate{QuantityRoom: 2}},
+		{StructuredField{Field: "floor", Transform: "floor"}, "VPR/4", RealEstate{Floor: 0.5, FloorTotal: 4}},
+		{StructuredField{Field: "link", Transform: "url"}, "/stan/1/", RealEstate{Link: "https://www.nekretnine.rs/stan/1/"}},
+		{StructuredField{Field: "currency", Transform: "currency"}, "EUR", RealEstate{Currency: "EUR"}},
+		{StructuredField{Field: "city", Transform: "text"}, " Beograd ", RealEstate{City: "Beograd"}},
+		{StructuredField{Field: "city", Transform: "text"}, map[string]any{"name": "Beograd"}, RealEstate{}},
+	}
+	for _, c := range cases {
+		var got RealEstate
+		c.field.apply(c.value, page, &got)
+		if got != c.want {
+			t.Errorf("%s %v: got %+v; want %+v", c.field.Field, c.value, got, c.want)
+		}
+	}
+}
+
+func TestStructuredSpecValidate(t *testing.T) {
+	spec := StructuredSpec{Fields: []StructuredField{{Field: "link", Path: "url"}, {Field: "district", Path: "address.addressRegion"}}}
+	if err := spec.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if spec.Script != defaultStructuredScript || spec.Fields[0].Transform != "url" || spec.Fields[1].Transform != "text" {
+		t.Errorf("defaults not applied: %+v", spec)
+	}
+
+	for _, bad := range []StructuredSpec{
+		{},
+		{Fields: []StructuredField{{Field: "price", Path: "offers.price"}}},
+		{Fields: []StructuredField{{Field: "link"}}},
+		{Fields: []StructuredField{{Field: "link", Path: "url"}, {Field: "price", Path: "price", Transform: "money"}}},
+		{Fields: []StructuredField{{Field: "link", Path: "url"}, {Field: "price", Path: "price", Transform: "currency"}}},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("%+v: expected an error", bad)
+		}
+	}
+}
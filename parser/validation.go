@@ -0,0 +1,63 @@
+package main
+
+import "math"
+
+// Plausible ranges for a Belgrade apartment. Zero values mean the card did
+// not show the field; missing fields are tracked by fill rates instead.
+const (
+	minValidSqm            = 8
+	maxValidSqm            = 2000
+	minValidRooms          = 0.5
+	maxValidRooms          = 15
+	pricePerSqmTolerance   = 0.1 // relative difference to Price/SquareMeter
+	atticFloor             = 1000
+	knownFloorTotalMinimum = 1
+)
+
+type validationRule struct {
+	name  string
+	valid func(e RealEstate) bool
+}
+
+// validationRules run in order before an estate is saved. Rule names are
+// stored in estates_quarantine and used as metric labels, so keep them stable.
+var validationRules = []validationRule{
+	{"link_present", func(e RealEstate) bool {
+		return e.Link != ""
+	}},
+	{"known_currency", func(e RealEstate) bool {
+		return e.Currency == "EUR" || e.Currency == "RSD"
+	}},
+	{"sqm_range", func(e RealEstate) bool {
+		return e.SquareMeter == 0 || (e.SquareMeter >= minValidSqm && e.SquareMeter <= maxValidSqm)
+	}},
+	{"rooms_range", func(e RealEstate) bool {
+		return e.QuantityRoom == 0 || (e.QuantityRoom >= minValidRooms && e.QuantityRoom <= maxValidRooms)
+	}},
+	{"floor_within_total", func(e RealEstate) bool {
+		// Attics are stored as 1000 and unknown parts as -5 or less.
+		if e.Floor <= dedupUnknownFloorLimit || e.Floor >= atticFloor || e.FloorTotal < knownFloorTotalMinimum {
+			return true
+		}
+		return e.Floor <= e.FloorTotal
+	}},
+	{"price_per_sqm_consistent", func(e RealEstate) bool {
+		if e.PricePerSquareMeter <= 0 || e.SquareMeter <= 0 {
+			return true
+		}
+		expected := float64(e.Price) / float64(e.SquareMeter)
+		// One unit of slack covers rounding of small rents.
+		return math.Abs(float64(e.PricePerSquareMeter)-expected) <= math.Max(1, expected*pricePerSqmTolerance)
+	}},
+}
+
+// validateEstate returns the names of the rules the estate violates.
+func validateEstate(e RealEstate) []string {
+	var failed []string
+	for _, rule := range validationRules {
+		if !rule.valid(e) {
+			failed = append(failed, rule.name)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func validEstate() RealEstate {
+	return RealEstate{
+		Price:               120000,
+		Currency:            "EUR",
+		PricePerSquareMeter: 2000,
+		SquareMeter:         60,
+		QuantityRoom:        2.5,
+		Floor:               3,
+		FloorTotal:          5,
+		Link:                "https://www.4zida.rs/prodaja-stanova/1",
+	}
+}
+
+func TestValidateEstate(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(e *RealEstate)
+		want   []string
+	}{
+		{"valid", func(e *RealEstate) {}, nil},
+		{"missing optional fields", func(e *RealEstate) {
+			e.SquareMeter, e.PricePerSquareMeter, e.QuantityRoom, e.Floor, e.FloorTotal = 0, 0, 0, 0, 0
+		}, nil},
+		{"no link", func(e *RealEstate) { e.Link = "" }, []string{"link_present"}},
+		{"unknown currency", func(e *RealEstate) { e.Currency = "" }, []string{"known_currency"}},
+		{"tiny area", func(e *RealEstate) { e.SquareMeter = 2; e.PricePerSquareMeter = 60000 }, []string{"sqm_range"}},
+		{"too many rooms", func(e *RealEstate) { e.QuantityRoom = 40 }, []string{"rooms_range"}},
+		{"floor above total", func(e *RealEstate) { e.Floor = 7 }, []string{"floor_within_total"}},
+		{"attic", func(e *RealEstate) { e.Floor = 1000 }, nil},
+		{"unknown floor part", func(e *RealEstate) { e.Floor = -100 }, nil},
+		{"basement", func(e *RealEstate) { e.Floor = -3 }, nil},
+		{"price per sqm off", func(e *RealEstate) { e.PricePerSquareMeter = 200 }, []string{"price_per_sqm_consistent"}},
+		{"price per sqm rounding", func(e *RealEstate) { e.PricePerSquareMeter = 2050 }, nil},
+		{"small rent rounding", func(e *RealEstate) {
+			e.Price, e.SquareMeter, e.PricePerSquareMeter = 450, 41, 12
+		}, nil},
+		{"several rules", func(e *RealEstate) { e.Currency = "USD"; e.Floor = 9 }, []string{"known_currency", "floor_within_total"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := validEstate()
+			c.modify(&e)
+			if got := validateEstate(e); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("validateEstate = %v; want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestPrintQuarantine(t *testing.T) {
+	e := validEstate()
+	e.Source = "4zida.rs"
+	e.Floor = 7
+
+	var buf bytes.Buffer
+	err := printQuarantine(&buf, []QuarantinedEstate{{
+		ID:            42,
+		Estate:        e,
+		Rules:         []string{"floor_within_total"},
+		QuarantinedAt: time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines; want 2:\n%s", len(lines), buf.String())
+	}
+	for _, want := range []string{"42", "2026-05-01 10:00:00", "4zida.rs", "floor_within_total", "120000 EUR", e.Link} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("row %q does not contain %q", lines[1], want)
+		}
+	}
+}